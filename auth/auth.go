@@ -24,7 +24,24 @@ type PerRPCAuth interface {
 
 }
 
+// PayloadAuth is an optional interface for PerRPCAuth whose metadata must be bound
+// to the request payload, e.g. request signing
+type PayloadAuth interface {
+
+	// GetPayloadMetadata fetch custom metadata computed from the request payload
+	GetPayloadMetadata(ctx context.Context, payload []byte, uri ... string) (map[string]string, error)
+
+}
+
 // AuthInfo defines the protocol type for authentication
 type AuthInfo interface {
 	AuthType() string
+}
+
+// PrincipalInfo is an optional interface for AuthInfo which identifies the authenticated peer
+type PrincipalInfo interface {
+
+	// Principal returns the identity of the peer, empty if the peer is not identified
+	Principal() string
+
 }
\ No newline at end of file
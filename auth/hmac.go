@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/interceptor"
+	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/stream"
+)
+
+// metadata keys used to carry the request signature
+const (
+	HMACKeyIDKey     = "gorpc-hmac-key-id"
+	HMACTimestampKey = "gorpc-hmac-timestamp"
+	HMACNonceKey     = "gorpc-hmac-nonce"
+	HMACSignatureKey = "gorpc-hmac-signature"
+)
+
+// DefaultClockSkew is the default max difference allowed between client and server clocks
+const DefaultClockSkew = 5 * time.Minute
+
+// hmacAuth signs every request with a shared key ID + secret,
+// it implements PerRPCAuth, PayloadAuth and AuthInfo
+type hmacAuth struct {
+	keyID  string
+	secret []byte
+}
+
+// NewHMACAuth creates a request signer with a key ID and a secret shared with the server,
+// the returned PerRPCAuth also implements PayloadAuth so that the client signs the payload
+func NewHMACAuth(keyID string, secret []byte) PerRPCAuth {
+	return &hmacAuth{
+		keyID:  keyID,
+		secret: secret,
+	}
+}
+
+// AuthType returns the protocol name
+func (h *hmacAuth) AuthType() string {
+	return "hmac"
+}
+
+// GetMetadata signs a request without payload, uri[0] is the service path
+func (h *hmacAuth) GetMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return h.GetPayloadMetadata(ctx, nil, uri...)
+}
+
+// GetPayloadMetadata signs the service path, a timestamp, a nonce and the payload hash,
+// uri[0] is the service path
+func (h *hmacAuth) GetPayloadMetadata(ctx context.Context, payload []byte, uri ...string) (map[string]string, error) {
+
+	if h.keyID == "" || len(h.secret) == 0 {
+		return nil, codes.ClientCertFailError
+	}
+
+	var servicePath string
+	if len(uri) > 0 {
+		servicePath = uri[0]
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	return map[string]string{
+		HMACKeyIDKey:     h.keyID,
+		HMACTimestampKey: timestamp,
+		HMACNonceKey:     nonce,
+		HMACSignatureKey: Sign(h.secret, servicePath, timestamp, nonce, payload),
+	}, nil
+}
+
+// Sign computes the hex encoded HMAC-SHA256 signature of a request
+func Sign(secret []byte, servicePath, timestamp, nonce string, payload []byte) string {
+	payloadHash := sha256.Sum256(payload)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{servicePath, timestamp, nonce, hex.EncodeToString(payloadHash[:])}, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HMACOptions defines the server side verification parameters
+type HMACOptions struct {
+	clockSkew time.Duration    // max difference allowed between client and server clocks
+	now       func() time.Time // clock, replaceable for testing
+}
+
+// HMACOption provides operations on HMACOptions
+type HMACOption func(*HMACOptions)
+
+// WithClockSkew sets the max difference allowed between client and server clocks
+func WithClockSkew(clockSkew time.Duration) HMACOption {
+	return func(o *HMACOptions) {
+		o.clockSkew = clockSkew
+	}
+}
+
+// WithClock sets the clock used to check the request timestamp
+func WithClock(now func() time.Time) HMACOption {
+	return func(o *HMACOptions) {
+		o.now = now
+	}
+}
+
+// BuildHMACInterceptor builds a server interceptor which verifies request signatures,
+// keys maps a key ID to its secret. The key ID is attached to the context as the principal.
+// A nonce can only be used once within the clock skew window, so a captured request can't be re-sent.
+func BuildHMACInterceptor(keys map[string][]byte, opts ...HMACOption) interceptor.ServerInterceptor {
+
+	o := &HMACOptions{
+		clockSkew: DefaultClockSkew,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	secrets := make(map[string][]byte, len(keys))
+	for k, v := range keys {
+		secrets[k] = v
+	}
+
+	cache := newNonceCache()
+
+	// verify returns the key ID of a valid signature
+	verify := func(ctx context.Context) (string, error) {
+		md := metadata.ServerMetadata(ctx)
+
+		keyID := string(md[HMACKeyIDKey])
+		timestamp := string(md[HMACTimestampKey])
+		nonce := string(md[HMACNonceKey])
+		signature := string(md[HMACSignatureKey])
+
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			return "", errors.New("signature missing")
+		}
+
+		secret, ok := secrets[keyID]
+		if !ok {
+			return "", errors.New("unknown key id")
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return "", errors.New("invalid timestamp")
+		}
+		now := o.now()
+		signedAt := time.Unix(ts, 0)
+		if signedAt.Before(now.Add(-o.clockSkew)) || signedAt.After(now.Add(o.clockSkew)) {
+			return "", errors.New("timestamp expired")
+		}
+
+		serverStream := stream.GetServerStream(ctx)
+		expected := Sign(secret, serverStream.ServicePath, timestamp, nonce, serverStream.Payload)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return "", errors.New("signature mismatch")
+		}
+
+		// only remember nonces of valid signatures, the nonce is useless after the skew window
+		if !cache.add(keyID+"/"+nonce, signedAt.Add(o.clockSkew), now) {
+			return "", errors.New("nonce replayed")
+		}
+
+		return keyID, nil
+	}
+
+	return func(ctx context.Context, req interface{}, handler interceptor.Handler) (interface{}, error) {
+		keyID, err := verify(ctx)
+		if err != nil {
+			return nil, codes.NewFrameworkError(codes.ClientCertFail, err.Error())
+		}
+
+		// the key ID identifies the caller for the authorization interceptors
+		return handler(WithPrincipal(ctx, keyID), req)
+	}
+}
+
+// nonceCache remembers the nonces seen until they expire
+type nonceCache struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time // nonce -> expire time
+	lastSweep time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// add returns false if the nonce has already been seen
+func (c *nonceCache) add(nonce string, expire time.Time, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// sweep expired nonces at most once per second to bound the cache size
+	if now.Sub(c.lastSweep) > time.Second {
+		for k, t := range c.nonces {
+			if t.Before(now) {
+				delete(c.nonces, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if t, ok := c.nonces[nonce]; ok && !t.Before(now) {
+		return false
+	}
+
+	c.nonces[nonce] = expire
+	return true
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/stream"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testServicePath = "/helloworld.Greeter/SayHello"
+
+func newSignedContext(t *testing.T, signer PerRPCAuth, payload []byte) context.Context {
+	payloadAuth, ok := signer.(PayloadAuth)
+	assert.True(t, ok)
+
+	authMd, err := payloadAuth.GetPayloadMetadata(context.Background(), payload, testServicePath)
+	assert.Nil(t, err)
+
+	md := make(map[string][]byte)
+	for k, v := range authMd {
+		md[k] = []byte(v)
+	}
+
+	ctx := metadata.WithServerMetadata(context.Background(), md)
+	ctx, ss := stream.NewServerStream(ctx)
+	ss.WithServicePath(testServicePath)
+	ss.WithPayload(payload)
+	return ctx
+}
+
+func okHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestHMACInterceptor(t *testing.T) {
+	signer := NewHMACAuth("key1", []byte("secret"))
+	cep := BuildHMACInterceptor(map[string][]byte{"key1": []byte("secret")})
+
+	ctx := newSignedContext(t, signer, []byte("hello"))
+	rsp, err := cep(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return PrincipalFromContext(ctx), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "key1", rsp)
+
+	// the same request can't be re-sent
+	_, err = cep(ctx, nil, okHandler)
+	assert.NotNil(t, err)
+
+	// payload tampered
+	ctx = newSignedContext(t, signer, []byte("hello"))
+	stream.GetServerStream(ctx).WithPayload([]byte("hacked"))
+	_, err = cep(ctx, nil, okHandler)
+	assert.NotNil(t, err)
+
+	// wrong secret
+	ctx = newSignedContext(t, NewHMACAuth("key1", []byte("guess")), []byte("hello"))
+	_, err = cep(ctx, nil, okHandler)
+	assert.NotNil(t, err)
+
+	// no signature at all
+	_, err = cep(context.Background(), nil, okHandler)
+	assert.NotNil(t, err)
+}
+
+func TestHMACClockSkew(t *testing.T) {
+	signer := NewHMACAuth("key1", []byte("secret"))
+	future := func() time.Time {
+		return time.Now().Add(time.Hour)
+	}
+	cep := BuildHMACInterceptor(map[string][]byte{"key1": []byte("secret")}, WithClockSkew(time.Minute), WithClock(future))
+
+	_, err := cep(newSignedContext(t, signer, nil), nil, okHandler)
+	assert.NotNil(t, err)
+}
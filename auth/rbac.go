@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/interceptor"
+	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/stream"
+
+	"gopkg.in/yaml.v2"
+)
+
+// rule effects
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Policy defines which principals may call which methods
+type Policy struct {
+	Roles map[string][]string `json:"roles" yaml:"roles"` // role groups, role name -> principals
+	Rules []Rule              `json:"rules" yaml:"rules"` // rules, deny takes precedence over allow
+}
+
+// Rule grants or denies the principals access to the methods
+type Rule struct {
+	Principals []string `json:"principals" yaml:"principals"` // principal names, "*" matches any authenticated principal
+	Roles      []string `json:"roles" yaml:"roles"`           // role groups defined in Policy.Roles
+	Methods    []string `json:"methods" yaml:"methods"`       // method patterns, e.g. : /helloworld.Greeter/*
+	Effect     string   `json:"effect" yaml:"effect"`         // allow or deny
+}
+
+// LoadPolicyFile loads a policy from a json or yaml file
+func LoadPolicyFile(file string) (*Policy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(data, policy)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, policy)
+	default:
+		return nil, fmt.Errorf("policy file %s not supported, only json and yaml are supported", file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, policy.validate()
+}
+
+func (p *Policy) validate() error {
+	for i, rule := range p.Rules {
+		if rule.Effect != Allow && rule.Effect != Deny {
+			return fmt.Errorf("rule %d invalid, effect %s is neither allow nor deny", i, rule.Effect)
+		}
+		for _, method := range rule.Methods {
+			if _, err := path.Match(method, ""); err != nil {
+				return fmt.Errorf("rule %d invalid, method pattern %s : %v", i, method, err)
+			}
+		}
+		for _, role := range rule.Roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("rule %d invalid, role %s not defined", i, role)
+			}
+		}
+	}
+	return nil
+}
+
+// Authorize returns whether the principal may call the method and the index of the decisive rule,
+// which is -1 if no rule matches. Methods are denied by default.
+func (p *Policy) Authorize(principal, method string) (bool, int) {
+	allowed, decisive := false, -1
+	for i, rule := range p.Rules {
+		if !p.matchPrincipal(rule, principal) || !matchMethod(rule.Methods, method) {
+			continue
+		}
+		if rule.Effect == Deny {
+			return false, i
+		}
+		if !allowed {
+			allowed, decisive = true, i
+		}
+	}
+	return allowed, decisive
+}
+
+// matchPrincipal never matches an unauthenticated caller, whose principal is empty
+func (p *Policy) matchPrincipal(rule Rule, principal string) bool {
+	if principal == "" {
+		return false
+	}
+	for _, pr := range rule.Principals {
+		if pr == "*" || pr == principal {
+			return true
+		}
+	}
+	for _, role := range rule.Roles {
+		for _, pr := range p.Roles[role] {
+			if pr == principal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal creates a new context with the authenticated principal attached,
+// it is called by the HMAC interceptor, by the transport for TLS client certificates,
+// and is supposed to be called by an AuthFunc after authentication
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext gets the authenticated principal from the context
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// BuildRBACInterceptor builds a server interceptor which authorizes every call against the policy.
+// It must be placed after the authentication interceptor, which attaches the principal by WithPrincipal.
+// Calls without a principal match no rule, so they are always denied.
+func BuildRBACInterceptor(policy *Policy) (interceptor.ServerInterceptor, error) {
+
+	if policy == nil {
+		return nil, codes.ConfigError
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, req interface{}, handler interceptor.Handler) (interface{}, error) {
+
+		principal := PrincipalFromContext(ctx)
+		method := stream.GetServerStream(ctx).ServicePath
+
+		allowed, rule := policy.Authorize(principal, method)
+
+		decision := Deny
+		if allowed {
+			decision = Allow
+		}
+		log.Infof("rbac audit, principal : %q, method : %s, decision : %s, rule : %d", principal, method, decision, rule)
+
+		if !allowed {
+			return nil, codes.PermissionDeniedError
+		}
+
+		return handler(ctx, req)
+	}, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/stream"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPolicy = &Policy{
+	Roles: map[string][]string{
+		"admin": {"alice"},
+	},
+	Rules: []Rule{
+		{Principals: []string{"bob"}, Methods: []string{"/helloworld.Greeter/*"}, Effect: Allow},
+		{Principals: []string{"bob"}, Methods: []string{"/helloworld.Greeter/Delete"}, Effect: Deny},
+		{Roles: []string{"admin"}, Methods: []string{"*"}, Effect: Allow},
+	},
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	allowed, rule := testPolicy.Authorize("bob", "/helloworld.Greeter/SayHello")
+	assert.True(t, allowed)
+	assert.Equal(t, 0, rule)
+
+	allowed, rule = testPolicy.Authorize("bob", "/helloworld.Greeter/Delete")
+	assert.False(t, allowed)
+	assert.Equal(t, 1, rule)
+
+	allowed, _ = testPolicy.Authorize("bob", "/other.Service/Get")
+	assert.False(t, allowed)
+
+	allowed, rule = testPolicy.Authorize("alice", "/other.Service/Get")
+	assert.True(t, allowed)
+	assert.Equal(t, 2, rule)
+
+	allowed, rule = testPolicy.Authorize("", "/helloworld.Greeter/SayHello")
+	assert.False(t, allowed)
+	assert.Equal(t, -1, rule)
+
+	// "*" matches any authenticated principal, but not an unauthenticated caller
+	anyone := &Policy{
+		Rules: []Rule{{Principals: []string{"*"}, Methods: []string{"*"}, Effect: Allow}},
+	}
+	allowed, _ = anyone.Authorize("carol", "/helloworld.Greeter/SayHello")
+	assert.True(t, allowed)
+	allowed, rule = anyone.Authorize("", "/helloworld.Greeter/SayHello")
+	assert.False(t, allowed)
+	assert.Equal(t, -1, rule)
+}
+
+func TestRBACInterceptor(t *testing.T) {
+	cep, err := BuildRBACInterceptor(testPolicy)
+	assert.Nil(t, err)
+
+	ctx, ss := stream.NewServerStream(context.Background())
+	ss.WithServicePath("/helloworld.Greeter/Delete")
+
+	_, err = cep(WithPrincipal(ctx, "bob"), nil, okHandler)
+	assert.Equal(t, codes.PermissionDeniedError, err)
+
+	rsp, err := cep(WithPrincipal(ctx, "alice"), nil, okHandler)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", rsp)
+
+	_, err = BuildRBACInterceptor(&Policy{Rules: []Rule{{Effect: "maybe"}}})
+	assert.NotNil(t, err)
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	yamlFile := filepath.Join(dir, "policy.yaml")
+	err = ioutil.WriteFile(yamlFile, []byte(`
+roles:
+  admin: [alice]
+rules:
+  - roles: [admin]
+    methods: ["/helloworld.Greeter/*"]
+    effect: allow
+`), 0644)
+	assert.Nil(t, err)
+
+	policy, err := LoadPolicyFile(yamlFile)
+	assert.Nil(t, err)
+	allowed, _ := policy.Authorize("alice", "/helloworld.Greeter/SayHello")
+	assert.True(t, allowed)
+
+	jsonFile := filepath.Join(dir, "policy.json")
+	err = ioutil.WriteFile(jsonFile, []byte(`{"rules":[{"principals":["*"],"methods":["*"],"effect":"deny"}]}`), 0644)
+	assert.Nil(t, err)
+
+	policy, err = LoadPolicyFile(jsonFile)
+	assert.Nil(t, err)
+	allowed, _ = policy.Authorize("alice", "/helloworld.Greeter/SayHello")
+	assert.False(t, allowed)
+}
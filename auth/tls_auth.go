@@ -13,7 +13,7 @@ import (
 )
 
 // tlsAuth defines the implementation of TLS authentication
-// and implements TransportAuth, PerRPCAuth, AuthInfo
+// and implements TransportAuth, PerRPCAuth, AuthInfo, PrincipalInfo
 type tlsAuth struct {
 	config *tls.Config
 	state tls.ConnectionState
@@ -24,6 +24,15 @@ func (t *tlsAuth) AuthType() string {
 	return "tls"
 }
 
+// Principal returns the common name of the verified peer certificate,
+// the server only gets one if the config requires client certificates
+func (t *tlsAuth) Principal() string {
+	if len(t.state.VerifiedChains) == 0 || len(t.state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return t.state.VerifiedChains[0][0].Subject.CommonName
+}
+
 // NewClientTLSAuthFromFile instantiates client-side authentication information
 // with certificates and service names
 func NewClientTLSAuthFromFile(certFile, serverName string) (TransportAuth, error) {
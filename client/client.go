@@ -3,12 +3,18 @@ package client
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/lubanproj/gorpc/auth"
 	"github.com/lubanproj/gorpc/codec"
 	"github.com/lubanproj/gorpc/codes"
 	"github.com/lubanproj/gorpc/interceptor"
+	"github.com/lubanproj/gorpc/log"
 	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/peer"
 	"github.com/lubanproj/gorpc/pool/connpool"
 	"github.com/lubanproj/gorpc/protocol"
 	"github.com/lubanproj/gorpc/selector"
@@ -58,8 +64,13 @@ func (c *defaultClient) Call(ctx context.Context, servicePath string, req interf
 
 func (c *defaultClient) Invoke(ctx context.Context, req , rsp interface{}, path string, opts ...Option) error {
 
+	// apply the call options to a copy, so that they don't leak into other calls
+	callOpts := *c.opts
 	for _, o := range opts {
-		o(c.opts)
+		o(&callOpts)
+	}
+	c = &defaultClient{
+		opts: &callOpts,
 	}
 
 	if c.opts.timeout > 0 {
@@ -79,10 +90,35 @@ func (c *defaultClient) Invoke(ctx context.Context, req , rsp interface{}, path
 	c.opts.serviceName = serviceName
 	c.opts.method = method
 
+	// a target URI picks the selector by its scheme
+	sel, ok, err := selector.GetTargetSelector(c.opts.target)
+	if err != nil {
+		return codes.NewFrameworkError(codes.ConfigErrorCode, err.Error())
+	}
+	if ok {
+		c.opts.selector = sel
+		if ns, ok := sel.(selector.NetworkSelector); ok && ns.Network() != "" {
+			c.opts.network = ns.Network()
+		}
+	}
+
 	// TODO : delete or not
 	clientStream.WithServiceName(serviceName)
 	clientStream.WithMethod(method)
 
+	// the transport records the picked node in a per-call peer,
+	// which is copied to the one given by WithPeer once the call finishes
+	p := &peer.Peer{
+		Network:  c.opts.network,
+		Protocol: c.opts.protocol,
+	}
+	newCtx = peer.NewContext(newCtx, p)
+	if c.opts.peer != nil {
+		defer func() {
+			*c.opts.peer = *p
+		}()
+	}
+
 	// execute the interceptor first
 	return interceptor.ClientIntercept(newCtx, req, rsp, c.opts.interceptors, c.invoke)
 }
@@ -95,18 +131,57 @@ func (c *defaultClient) invoke(ctx context.Context, req, rsp interface{}) error
 		return codes.NewFrameworkError(codes.ClientMsgErrorCode, "request marshal failed ...")
 	}
 
+	var response *protocol.Response
+	if c.opts.hedgingPolicy != nil {
+		response, err = c.hedge(ctx, payload)
+	} else {
+		response, err = c.retry(ctx, payload)
+	}
+
+	if response != nil && c.opts.rspMetadata != nil {
+		*c.opts.rspMetadata = responseMetadata(response.Metadata)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return serialization.Unmarshal(response.Payload, rsp)
+
+}
+
+// attempt sends the request to a node picked by the selector once, the response is
+// returned along with the error if the server returns an error
+func (c *defaultClient) attempt(ctx context.Context, payload []byte, sel selector.Selector) (response *protocol.Response, err error) {
+
+	// the result is reported to the circuit breaker of the node picked, and the balancer
+	// which picked it is told that the call completed
+	start := time.Now()
+	var done func()
+	defer func() {
+		if done != nil {
+			done()
+		}
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			selector.Report(c.opts.serviceName, p.Addr.String(), time.Since(start), err)
+		}
+	}()
+
 	clientCodec := codec.GetCodec(c.opts.protocol)
 
-	// assemble header
-	request := addReqHeader(ctx, c, payload)
+	// assemble header, each attempt has its own deadline and signature
+	request, err := addReqHeader(ctx, c, payload)
+	if err != nil {
+		return nil, err
+	}
 	reqbuf, err := proto.Marshal(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	reqbody, err := clientCodec.Encode(reqbuf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	clientTransport := c.NewClientTransport()
@@ -115,46 +190,128 @@ func (c *defaultClient) invoke(ctx context.Context, req, rsp interface{}) error
 		transport.WithClientTarget(c.opts.target),
 		transport.WithClientNetwork(c.opts.network),
 		transport.WithClientPool(connpool.GetPool("default")),
-		transport.WithSelector(selector.GetSelector(c.opts.selectorName)),
+		transport.WithSelector(sel),
+		transport.WithSelectOptions(append(c.selectOptions(ctx), selector.WithDone(&done))...),
 		transport.WithTimeout(c.opts.timeout),
+		transport.WithClientTransportAuth(c.opts.transportAuth),
 	}
 	frame, err := clientTransport.Send(ctx, reqbody, clientTransportOpts ...)
 	if err != nil {
-		return err
+		return nil, codes.FromContextError(err)
 	}
 
 	rspbuf, err := clientCodec.Decode(frame)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// parse protocol header
-	response := &protocol.Response{}
+	response = &protocol.Response{}
 	if err = proto.Unmarshal(rspbuf, response); err != nil {
-		return err
+		return nil, err
 	}
 
 	if response.RetCode != 0 {
-		return codes.New(response.RetCode, response.RetMsg)
+		return response, rspError(response)
 	}
 
-	return serialization.Unmarshal(response.Payload, rsp)
+	return response, nil
+}
 
+// selector returns the selector of the target URI if any, or the selector named by WithSelectorName
+func (c *defaultClient) selector() selector.Selector {
+	if c.opts.selector != nil {
+		return c.opts.selector
+	}
+	return selector.GetSelector(c.opts.selectorName)
+}
+
+// selectOptions returns the options of the node selection of the call
+func (c *defaultClient) selectOptions(ctx context.Context) []selector.Option {
+	key := c.opts.hashKey
+	if key == "" && c.opts.hashKeyMetadata != "" {
+		for k, v := range metadata.OutgoingMetadata(ctx) {
+			if strings.EqualFold(k, c.opts.hashKeyMetadata) {
+				key = string(v)
+				break
+			}
+		}
+	}
+	if key == "" {
+		return nil
+	}
+	return []selector.Option{selector.WithHashKey(key)}
+}
+
+// rspError rebuilds the error returned by the server, servers which don't send
+// the error type are supposed to return business errors
+func rspError(response *protocol.Response) error {
+	e := codes.New(response.RetCode, response.RetMsg)
+
+	if t, err := strconv.Atoi(string(response.Metadata[metadata.ErrorTypeKey])); err == nil {
+		e.Type = t
+	}
+
+	if data := response.Metadata[metadata.ErrorDetailsKey]; len(data) > 0 {
+		details, err := codes.DecodeDetails(data)
+		if err != nil {
+			log.Errorf("decode error details error, %v", err)
+		}
+		e.Details = details
+	}
+
+	return e
+}
+
+// responseMetadata returns the metadata set by the server without the reserved keys,
+// e.g. : the type and the details of the error, which Invoke returns as the error
+func responseMetadata(md map[string][]byte) map[string][]byte {
+	rsp := make(map[string][]byte, len(md))
+	for k, v := range md {
+		if !metadata.IsReserved(k) {
+			rsp[k] = v
+		}
+	}
+	return rsp
 }
 
 func (c *defaultClient) NewClientTransport() transport.ClientTransport {
 	return transport.GetClientTransport(c.opts.protocol)
 }
 
-func addReqHeader(ctx context.Context, client *defaultClient, payload []byte) *protocol.Request {
+func addReqHeader(ctx context.Context, client *defaultClient, payload []byte) (*protocol.Request, error) {
 	clientStream := stream.GetClientStream(ctx)
 
 	servicePath := fmt.Sprintf("/%s/%s", clientStream.ServiceName, clientStream.Method)
-	md := metadata.ClientMetadata(ctx)
+
+	// a copy of the forwarded and the client metadata, the maps in ctx are shared with other calls
+	md := metadata.OutgoingMetadata(ctx)
+
+	// propagate the remaining time budget to the server
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return nil, codes.DeadlineExceededError
+		}
+		ms := int64(timeout / time.Millisecond)
+		if ms == 0 {
+			ms = 1
+		}
+		md[metadata.TimeoutKey] = []byte(strconv.FormatInt(ms, 10))
+	}
 
 	// fill the authentication information
 	for _, pra := range client.opts.perRPCAuth {
-		authMd, _ := pra.GetMetadata(ctx)
+		var authMd map[string]string
+		var err error
+		if pa, ok := pra.(auth.PayloadAuth); ok {
+			authMd, err = pa.GetPayloadMetadata(ctx, payload, servicePath)
+		} else {
+			authMd, err = pra.GetMetadata(ctx, servicePath)
+		}
+		if err != nil {
+			return nil, err
+		}
 		for k, v := range authMd {
 			md[k] = []byte(v)
 		}
@@ -166,12 +323,5 @@ func addReqHeader(ctx context.Context, client *defaultClient, payload []byte) *p
 		Metadata: md,
 	}
 
-	return request
+	return request, nil
 }
-
-
-
-
-
-
-
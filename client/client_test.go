@@ -2,11 +2,24 @@ package client
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/lubanproj/gorpc"
+	"github.com/lubanproj/gorpc/codec"
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/interceptor"
+	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/protocol"
+	"github.com/lubanproj/gorpc/selector"
+	"github.com/lubanproj/gorpc/stream"
 	"github.com/lubanproj/gorpc/testdata"
+	"github.com/lubanproj/gorpc/transport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,6 +32,10 @@ func TestCall(t *testing.T) {
 			gorpc.WithNetwork("tcp"),
 			gorpc.WithSerializationType("msgpack"),
 			gorpc.WithTimeout(time.Millisecond * 2000),
+			gorpc.WithInterceptor(func(ctx context.Context, req interface{}, handler interceptor.Handler) (interface{}, error) {
+				metadata.SetHeader(ctx, map[string][]byte{"server-timing": []byte("1ms")})
+				return handler(ctx, req)
+			}),
 		}
 		s := gorpc.NewServer(serverOpts ...)
 		if err := s.RegisterService("helloworld.Greeter", new(testdata.Service)); err != nil {
@@ -35,7 +52,11 @@ func TestCall(t *testing.T) {
 
 	time.Sleep(1000 * time.Millisecond)
 
+	p := &peer.Peer{}
+	var rspMd map[string][]byte
 	opts := []Option {
+		WithPeer(p),
+		WithResponseMetadata(&rspMd),
 		WithTarget("127.0.0.1:8001"),
 		WithNetwork("tcp"),
 		WithTimeout(2000 * time.Millisecond),
@@ -52,5 +73,234 @@ func TestCall(t *testing.T) {
 	close(ch)
 
 	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:8001", p.Addr.String())
+	assert.NotNil(t, p.LocalAddr)
+	assert.Equal(t, "1ms", string(rspMd["server-timing"]))
 }
 
+
+func TestAddReqHeaderTimeout(t *testing.T) {
+	c := New()
+	ctx, cs := stream.NewClientStream(context.Background())
+	cs.WithServiceName("helloworld.Greeter")
+	cs.WithMethod("SayHello")
+
+	request, err := addReqHeader(ctx, c, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/helloworld.Greeter/SayHello", request.ServicePath)
+	_, ok := request.Metadata[metadata.TimeoutKey]
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	request, err = addReqHeader(ctx, c, nil)
+	assert.Nil(t, err)
+	ms, err := strconv.Atoi(string(request.Metadata[metadata.TimeoutKey]))
+	assert.Nil(t, err)
+	assert.True(t, ms > 0 && ms <= 1000)
+}
+
+func TestRspError(t *testing.T) {
+	// servers without the error type return business errors
+	err := rspError(&protocol.Response{RetCode: 1001, RetMsg: "biz"})
+	assert.Equal(t, codes.New(1001, "biz"), err)
+
+	details, e := codes.EncodeDetails([]codes.Detail{&codes.RetryInfo{RetryDelay: time.Second}})
+	assert.Nil(t, e)
+	err = rspError(&protocol.Response{
+		RetCode: codes.DeadlineExceeded,
+		RetMsg:  "timeout",
+		Metadata: map[string][]byte{
+			metadata.ErrorTypeKey:    []byte("1"),
+			metadata.ErrorDetailsKey: details,
+		},
+	})
+	assert.True(t, errors.Is(err, codes.DeadlineExceededError))
+	ce, ok := codes.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, ce.Details[0].(*codes.RetryInfo).RetryDelay)
+}
+
+func TestResponseMetadata(t *testing.T) {
+	md := responseMetadata(map[string][]byte{
+		"server-timing":          []byte("1ms"),
+		metadata.ErrorTypeKey:    []byte("1"),
+		metadata.ErrorDetailsKey: []byte("details"),
+	})
+	assert.Equal(t, map[string][]byte{"server-timing": []byte("1ms")}, md)
+}
+
+var testNodes = []*selector.Node{
+	{Address: "10.0.0.1:8000"},
+	{Address: "10.0.0.2:8000"},
+	{Address: "10.0.0.3:8000"},
+}
+
+// testSelector picks one of testNodes
+type testSelector struct{}
+
+func (s *testSelector) Select(serviceName string, opts ...selector.Option) (string, error) {
+	node, err := selector.Pick(serviceName, testNodes, selector.GetBalancer(selector.RoundRobin), opts...)
+	if err != nil {
+		return "", err
+	}
+	return node.Address, nil
+}
+
+// fakeTransport fails with the errors in order, then replies with an empty HelloReply
+type fakeTransport struct {
+	mu    sync.Mutex
+	errs  []error
+	delay func(addr string) time.Duration
+	addrs []string
+}
+
+func (f *fakeTransport) Send(ctx context.Context, req []byte, opts ...transport.ClientTransportOption) ([]byte, error) {
+	o := &transport.ClientTransportOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	addr, err := o.Selector.Select(o.ServiceName, o.SelectOptions...)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		p.Addr = peer.NewAddr("tcp", addr)
+	}
+
+	f.mu.Lock()
+	f.addrs = append(f.addrs, addr)
+	var sendErr error
+	if len(f.errs) > 0 {
+		sendErr, f.errs = f.errs[0], f.errs[1:]
+	}
+	f.mu.Unlock()
+
+	if f.delay != nil {
+		select {
+		case <-time.After(f.delay(addr)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if sendErr != nil {
+		return nil, sendErr
+	}
+
+	payload, err := codec.GetSerialization(codec.MsgPack).Marshal(&testdata.HelloReply{Msg: addr})
+	if err != nil {
+		return nil, err
+	}
+	rspbuf, err := proto.Marshal(&protocol.Response{Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	return codec.DefaultCodec.Encode(rspbuf)
+}
+
+func (f *fakeTransport) tried() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.addrs...)
+}
+
+func newFakeClient(name string, ft *fakeTransport) (*defaultClient, []Option) {
+	transport.RegisterClientTransport(name, ft)
+	selector.RegisterSelector(name, &testSelector{})
+	return New(), []Option{
+		WithProtocol(name),
+		WithSelectorName(name),
+		WithNetwork("tcp"),
+		WithSerializationType(codec.MsgPack),
+	}
+}
+
+func TestBreakerReport(t *testing.T) {
+	defer selector.SetBreakerConfig(selector.DefaultBreakerConfig)
+	selector.SetBreakerConfig(selector.BreakerConfig{
+		ConsecutiveFailures: 1,
+		CoolDown:            time.Minute,
+	})
+
+	// the node failing is skipped by the later calls
+	ft := &fakeTransport{errs: []error{codes.UnavailableError}}
+	c, opts := newFakeClient("breaker1", ft)
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/breaker.Greeter/SayHello", opts...)
+	assert.Equal(t, codes.UnavailableError, err)
+	failed := ft.tried()[0]
+	assert.Equal(t, selector.StateOpen, selector.GetBreakerState("breaker.Greeter", failed))
+
+	for i := 0; i < 6; i++ {
+		err = c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/breaker.Greeter/SayHello", opts...)
+		assert.Nil(t, err)
+	}
+	for _, addr := range ft.tried()[1:] {
+		assert.NotEqual(t, failed, addr)
+	}
+}
+
+// hashSelector picks one of testNodes by consistent hash
+type hashSelector struct{}
+
+func (s *hashSelector) Select(serviceName string, opts ...selector.Option) (string, error) {
+	node, err := selector.Pick(serviceName, testNodes, selector.GetBalancer(selector.ConsistentHash), opts...)
+	if err != nil {
+		return "", err
+	}
+	return node.Address, nil
+}
+
+func TestHashKey(t *testing.T) {
+	ft := &fakeTransport{}
+	c, opts := newFakeClient("hash1", ft)
+	selector.RegisterSelector("hash1", &hashSelector{})
+
+	for i := 0; i < 5; i++ {
+		err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+			append(opts, WithHashKey("user-123"))...)
+		assert.Nil(t, err)
+	}
+
+	// the key is read from the outgoing metadata
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.New(map[string]string{"user-id": "user-123"}))
+	for i := 0; i < 5; i++ {
+		err := c.Invoke(ctx, &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+			append(opts, WithHashKeyMetadata("User-Id"))...)
+		assert.Nil(t, err)
+	}
+
+	tried := ft.tried()
+	for _, addr := range tried {
+		assert.Equal(t, tried[0], addr)
+	}
+}
+
+func TestTargetScheme(t *testing.T) {
+	ft := &fakeTransport{}
+	c, opts := newFakeClient("target1", ft)
+	opts = append(opts, WithTarget("ip://10.0.1.1:8000,10.0.1.2:8000"))
+
+	for i := 0; i < 4; i++ {
+		err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello", opts...)
+		assert.Nil(t, err)
+	}
+	count := make(map[string]int)
+	for _, addr := range ft.tried() {
+		count[addr]++
+	}
+	assert.Equal(t, 2, count["10.0.1.1:8000"])
+	assert.Equal(t, 2, count["10.0.1.2:8000"])
+
+	// the network of the target is used
+	p := &peer.Peer{}
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithTarget("unix:///tmp/gorpc.sock"), WithPeer(p))...)
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", p.Network)
+	assert.Equal(t, "/tmp/gorpc.sock", p.Addr.String())
+
+	err = c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithTarget("etcd://svc"))...)
+	assert.Equal(t, uint32(codes.ConfigErrorCode), codes.Code(err))
+}
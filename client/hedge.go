@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/protocol"
+)
+
+// minLatencySamples is the number of latencies observed before the delay percentile is used
+const minLatencySamples = 20
+
+// latencyWindowSize is the number of latest latencies kept per method
+const latencyWindowSize = 128
+
+// HedgingPolicy defines how a call is hedged : if no response arrives after a delay, the same request
+// is sent to another node, the first successful response is taken and the other attempts are canceled.
+// Only idempotent methods are hedged, which are either listed in IdempotentMethods or marked by WithIdempotent.
+// A call is not retried when it's hedged.
+type HedgingPolicy struct {
+	MaxAttempts       int           // max number of attempts including the first one, no hedge if <= 1
+	Delay             time.Duration // delay before sending each hedge
+	DelayPercentile   float64       // if > 0, the delay is this percentile of the latencies observed for the method, e.g. : 95
+	MaxOutstanding    int64         // max number of hedges in flight of all the calls using the policy, 0 means no limit
+	NonFatalCodes     []uint32      // codes of the errors after which the other attempts go on, Unavailable if empty
+	IdempotentMethods []string      // patterns of the methods safe to hedge, e.g. : /helloworld.Greeter/Get*
+
+	outstanding int64 // hedges in flight
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow // service path -> latencies of the successful attempts
+}
+
+// latencyWindow keeps the latest latencies
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, latency)
+		return
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := append([]time.Duration(nil), w.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(float64(len(sorted)) * p / 100)
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (p *HedgingPolicy) idempotent(servicePath string) bool {
+	for _, pattern := range p.IdempotentMethods {
+		if ok, _ := path.Match(pattern, servicePath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *HedgingPolicy) fatal(err error) bool {
+	code := codes.Code(err)
+	if len(p.NonFatalCodes) == 0 {
+		return code != codes.Unavailable
+	}
+	for _, c := range p.NonFatalCodes {
+		if c == code {
+			return false
+		}
+	}
+	return true
+}
+
+// observe records the latency of a successful attempt
+func (p *HedgingPolicy) observe(servicePath string, latency time.Duration) {
+	if p.DelayPercentile <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.latencies == nil {
+		p.latencies = make(map[string]*latencyWindow)
+	}
+	w, ok := p.latencies[servicePath]
+	if !ok {
+		w = &latencyWindow{}
+		p.latencies[servicePath] = w
+	}
+	w.add(latency)
+}
+
+// delay returns the delay before a hedge, Delay is used until enough latencies are observed
+func (p *HedgingPolicy) delay(servicePath string) time.Duration {
+	if p.DelayPercentile <= 0 {
+		return p.Delay
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, ok := p.latencies[servicePath]
+	if !ok || len(w.samples) < minLatencySamples {
+		return p.Delay
+	}
+	return w.percentile(p.DelayPercentile)
+}
+
+// acquire takes a slot of the outstanding hedges
+func (p *HedgingPolicy) acquire() bool {
+	if p.MaxOutstanding <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&p.outstanding, 1) > p.MaxOutstanding {
+		atomic.AddInt64(&p.outstanding, -1)
+		return false
+	}
+	return true
+}
+
+func (p *HedgingPolicy) release() {
+	if p.MaxOutstanding > 0 {
+		atomic.AddInt64(&p.outstanding, -1)
+	}
+}
+
+// hedgeResult is the result of a hedged attempt
+type hedgeResult struct {
+	response *protocol.Response
+	err      error
+	peer     *peer.Peer
+}
+
+// hedge sends the request to other nodes if no response arrives in time, and takes the first successful response
+func (c *defaultClient) hedge(ctx context.Context, payload []byte) (*protocol.Response, error) {
+
+	policy := c.opts.hedgingPolicy
+	servicePath := "/" + c.opts.serviceName + "/" + c.opts.method
+	sel := c.selector()
+
+	if policy.MaxAttempts <= 1 || !(c.opts.idempotent || policy.idempotent(servicePath)) {
+		return c.retry(ctx, payload)
+	}
+
+	callPeer, ok := peer.FromContext(ctx)
+	if !ok {
+		callPeer = &peer.Peer{}
+	}
+
+	// the attempts still in flight are canceled on return
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	recorder := newPickRecorder(sel)
+	results := make(chan hedgeResult, policy.MaxAttempts)
+
+	launch := func(hedged bool) {
+		go func() {
+			if hedged {
+				defer policy.release()
+			}
+			p := newAttemptPeer(callPeer)
+			start := time.Now()
+			response, err := c.attempt(peer.NewContext(ctx, p), payload, recorder)
+			if err == nil {
+				policy.observe(servicePath, time.Since(start))
+			}
+			results <- hedgeResult{response: response, err: err, peer: p}
+		}()
+	}
+
+	launch(false)
+	launched, pending := 1, 1
+
+	delay := policy.delay(servicePath)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			last = result
+			if result.err == nil || policy.fatal(result.err) {
+				*callPeer = *result.peer
+				return result.response, result.err
+			}
+			// a non fatal error, hedge at once
+			if launched < policy.MaxAttempts && policy.acquire() {
+				launch(true)
+				launched++
+				pending++
+			}
+		case <-timer.C:
+			if launched < policy.MaxAttempts && policy.acquire() {
+				launch(true)
+				launched++
+				pending++
+			}
+			if launched < policy.MaxAttempts {
+				timer.Reset(delay)
+			}
+		case <-ctx.Done():
+			return nil, codes.FromContextError(ctx.Err())
+		}
+	}
+
+	*callPeer = *last.peer
+	return last.response, last.err
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/testdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowFirstNode makes the first node tried answer after a second
+func slowFirstNode() func(addr string) time.Duration {
+	var mu sync.Mutex
+	var first string
+	return func(addr string) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		if first == "" {
+			first = addr
+		}
+		if addr == first {
+			return time.Second
+		}
+		return 0
+	}
+}
+
+func TestHedge(t *testing.T) {
+	policy := &HedgingPolicy{
+		MaxAttempts: 3,
+		Delay:       20 * time.Millisecond,
+	}
+
+	// the hedge answers before the slow node
+	ft := &fakeTransport{delay: slowFirstNode()}
+	c, opts := newFakeClient("hedge1", ft)
+	p := &peer.Peer{}
+	rsp := &testdata.HelloReply{}
+	start := time.Now()
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, rsp, "/helloworld.Greeter/SayHello",
+		append(opts, WithHedgingPolicy(policy), WithIdempotent(), WithPeer(p))...)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	tried := ft.tried()
+	assert.Equal(t, 2, len(tried))
+	assert.NotEqual(t, tried[0], tried[1])
+	assert.Equal(t, tried[1], rsp.Msg)
+	assert.Equal(t, tried[1], p.Addr.String())
+
+	// a non fatal error is hedged at once
+	ft = &fakeTransport{errs: []error{codes.UnavailableError}}
+	c, opts = newFakeClient("hedge2", ft)
+	policy.Delay = time.Second
+	start = time.Now()
+	err = c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithHedgingPolicy(policy), WithIdempotent())...)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.Equal(t, 2, len(ft.tried()))
+
+	// a fatal error ends the call
+	ft = &fakeTransport{errs: []error{codes.PermissionDeniedError}}
+	c, opts = newFakeClient("hedge3", ft)
+	err = c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithHedgingPolicy(policy), WithIdempotent())...)
+	assert.Equal(t, codes.PermissionDeniedError, err)
+	assert.Equal(t, 1, len(ft.tried()))
+}
+
+func TestHedgeNotIdempotent(t *testing.T) {
+	policy := &HedgingPolicy{
+		MaxAttempts: 3,
+		Delay:       10 * time.Millisecond,
+	}
+
+	ft := &fakeTransport{delay: slowFirstNode()}
+	c, opts := newFakeClient("hedge4", ft)
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithHedgingPolicy(policy))...)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ft.tried()))
+}
+
+func TestHedgeMaxOutstanding(t *testing.T) {
+	policy := &HedgingPolicy{
+		MaxAttempts:    3,
+		Delay:          10 * time.Millisecond,
+		MaxOutstanding: 1,
+	}
+	// the slot is taken by another call
+	assert.True(t, policy.acquire())
+
+	ft := &fakeTransport{
+		delay: func(addr string) time.Duration {
+			return 100 * time.Millisecond
+		},
+	}
+	c, opts := newFakeClient("hedge5", ft)
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithHedgingPolicy(policy), WithIdempotent())...)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ft.tried()))
+
+	policy.release()
+	assert.True(t, policy.acquire())
+	assert.False(t, policy.acquire())
+	policy.release()
+}
+
+func TestHedgeDelayPercentile(t *testing.T) {
+	policy := &HedgingPolicy{
+		Delay:           time.Second,
+		DelayPercentile: 90,
+	}
+	servicePath := "/helloworld.Greeter/SayHello"
+
+	// Delay is used until enough latencies are observed
+	for i := 1; i < minLatencySamples; i++ {
+		policy.observe(servicePath, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, time.Second, policy.delay(servicePath))
+
+	for i := minLatencySamples; i <= 100; i++ {
+		policy.observe(servicePath, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 91*time.Millisecond, policy.delay(servicePath))
+	assert.Equal(t, time.Second, policy.delay("/helloworld.Greeter/Other"))
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/lubanproj/gorpc/auth"
 	"github.com/lubanproj/gorpc/interceptor"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/selector"
 	"github.com/lubanproj/gorpc/transport"
 )
 
@@ -12,7 +14,7 @@ import (
 type Options struct {
 	serviceName string // service name
 	method string // method name
-	target string 	// format e.g.:  ip:port 127.0.0.1:8000
+	target string 	// format e.g.:  ip:port 127.0.0.1:8000, or a target URI, e.g. : ip://10.0.0.1:8000,10.0.0.2:8000
 	timeout time.Duration  // timeout
 	network string  // network type, e.g.:  tcp、udp
 	protocol   string  // protocol type , e.g. : proto、json
@@ -20,8 +22,16 @@ type Options struct {
 	transportOpts transport.ClientTransportOptions
 	interceptors []interceptor.ClientInterceptor
 	selectorName string      // service discovery name, e.g. : consul、zookeeper、etcd
+	selector selector.Selector  // selector of the target URI, it takes precedence over selectorName
 	perRPCAuth []auth.PerRPCAuth  // authentication information required for each RPC call
 	transportAuth auth.TransportAuth
+	peer *peer.Peer  // filled with the information of the node called
+	rspMetadata *map[string][]byte  // filled with the response metadata set by the server
+	retryPolicy *RetryPolicy  // retries the failed calls of idempotent methods
+	idempotent bool  // the call is safe to retry
+	hedgingPolicy *HedgingPolicy  // hedges the calls of idempotent methods
+	hashKey string  // routes the call by the key if the balancer is a consistent hash one
+	hashKeyMetadata string  // metadata key whose outgoing value is the hash key if no hash key is given
 }
 
 type Option func(*Options)
@@ -38,6 +48,16 @@ func WithMethod(method string) Option {
 	}
 }
 
+// WithTarget returns an Option which sets the target of the calls, either an address such as 127.0.0.1:8000,
+// or a target URI whose scheme picks the selector, which takes precedence over WithSelectorName :
+//
+//	ip://10.0.0.1:8000,10.0.0.2:8000   a static list of nodes
+//	dns://svc.local:8000                the addresses of a domain
+//	consul://svc-name                   the nodes of a service registered in consul
+//	unix:///path/to/socket              a unix domain socket
+//
+// The balancer of a target is RoundRobin unless set by a query, e.g. : ip://10.0.0.1:8000,10.0.0.2:8000?balancer=p2c.
+// New schemes are registered by selector.RegisterScheme.
 func WithTarget(target string) Option {
 	return func(o *Options) {
 		o.target = target
@@ -92,3 +112,58 @@ func WithTransportAuth(transportAuth auth.TransportAuth) Option {
 	}
 }
 
+
+
+// WithPeer returns an Option which fills p with the information of the node called once the call finishes,
+// p belongs to a single call and must not be shared by concurrent calls
+func WithPeer(p *peer.Peer) Option {
+	return func(o *Options) {
+		o.peer = p
+	}
+}
+
+// WithResponseMetadata returns an Option which fills md with the response metadata set by the server,
+// it's filled even if the call fails with an error returned by the server. The reserved keys are left out.
+func WithResponseMetadata(md *map[string][]byte) Option {
+	return func(o *Options) {
+		o.rspMetadata = md
+	}
+}
+
+// WithRetryPolicy returns an Option which retries the failed calls by the policy
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *Options) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithIdempotent returns an Option which marks the call safe to retry and hedge
+func WithIdempotent() Option {
+	return func(o *Options) {
+		o.idempotent = true
+	}
+}
+
+// WithHedgingPolicy returns an Option which hedges the calls by the policy,
+// the policy keeps the latencies observed and must be shared by the calls
+func WithHedgingPolicy(policy *HedgingPolicy) Option {
+	return func(o *Options) {
+		o.hedgingPolicy = policy
+	}
+}
+
+// WithHashKey returns an Option which routes the call by the key, the calls of
+// a key go to the same node if the balancer is a consistent hash one
+func WithHashKey(key string) Option {
+	return func(o *Options) {
+		o.hashKey = key
+	}
+}
+
+// WithHashKeyMetadata returns an Option which routes the call by the outgoing
+// metadata value of the key, e.g. : user-id, it is ignored if WithHashKey is given
+func WithHashKeyMetadata(key string) Option {
+	return func(o *Options) {
+		o.hashKeyMetadata = key
+	}
+}
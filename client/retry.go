@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/protocol"
+	"github.com/lubanproj/gorpc/selector"
+)
+
+// default values of RetryPolicy
+const (
+	DefaultBackoffMultiplier = 2
+	DefaultJitter            = 0.2
+)
+
+// RetryPolicy defines how a failed call is retried. Only idempotent methods are retried,
+// which are either listed in IdempotentMethods or marked by WithIdempotent for a call.
+type RetryPolicy struct {
+	MaxAttempts       int           // max number of attempts including the first one, no retry if <= 1
+	InitialBackoff    time.Duration // backoff before the first retry
+	MaxBackoff        time.Duration // max backoff, no limit if 0
+	BackoffMultiplier float64       // growth factor of the backoff, DefaultBackoffMultiplier if 0
+	Jitter            float64       // the backoff is randomized within ±Jitter of it, DefaultJitter if 0, no jitter if < 0
+	RetryableCodes    []uint32      // codes of the errors to retry, Unavailable if empty
+	PerAttemptTimeout time.Duration // timeout of each attempt, an attempt timing out is retried, 0 means no limit
+	IdempotentMethods []string      // patterns of the methods safe to retry, e.g. : /helloworld.Greeter/Get*
+	Budget            *RetryBudget  // caps the retries to a percentage of the calls, nil means no cap
+}
+
+// idempotent returns whether the method matches the patterns of IdempotentMethods
+func (p *RetryPolicy) idempotent(servicePath string) bool {
+	for _, pattern := range p.IdempotentMethods {
+		if ok, _ := path.Match(pattern, servicePath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable returns whether err is retried by the policy
+func (p *RetryPolicy) retryable(err error) bool {
+	code := codes.Code(err)
+	if len(p.RetryableCodes) == 0 {
+		return code == codes.Unavailable
+	}
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the duration to wait before the nth retry, a RetryInfo detail sent by the server takes precedence
+func (p *RetryPolicy) backoff(n int, err error) time.Duration {
+	if e, ok := codes.FromError(err); ok && e != nil {
+		for _, d := range e.Details {
+			if info, ok := d.(*codes.RetryInfo); ok {
+				return info.RetryDelay
+			}
+		}
+	}
+
+	multiplier := p.BackoffMultiplier
+	if multiplier == 0 {
+		multiplier = DefaultBackoffMultiplier
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(n-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	jitter := p.Jitter
+	if jitter == 0 {
+		jitter = DefaultJitter
+	}
+	if jitter > 0 {
+		backoff *= 1 + jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(backoff)
+}
+
+// RetryBudget caps the retries to a percentage of the calls, so that retries can't
+// multiply the load of a struggling service. It is shared by the calls using the policy.
+type RetryBudget struct {
+	mu        sync.Mutex
+	perCall   int64 // thousandths of a token deposited by a call
+	maxTokens int64
+	tokens    int64 // thousandths of tokens, a retry takes one token
+}
+
+// NewRetryBudget creates a budget which allows retries of up to percent of the calls,
+// minRetries is a reserve for low traffic. At most the tokens deposited by the latest 1000 calls are kept.
+func NewRetryBudget(percent float64, minRetries int) *RetryBudget {
+	perCall := int64(math.Round(percent * 10))
+	return &RetryBudget{
+		perCall:   perCall,
+		maxTokens: int64(minRetries)*1000 + 1000*perCall,
+		tokens:    int64(minRetries) * 1000,
+	}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens += b.perCall; b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1000 {
+		return false
+	}
+	b.tokens -= 1000
+	return true
+}
+
+// retry calls attempt until it succeeds or the retry policy gives up, each retry
+// goes to a node which has not been tried if there is one
+func (c *defaultClient) retry(ctx context.Context, payload []byte) (*protocol.Response, error) {
+
+	policy := c.opts.retryPolicy
+	servicePath := "/" + c.opts.serviceName + "/" + c.opts.method
+	sel := c.selector()
+
+	if policy == nil || policy.MaxAttempts <= 1 || !(c.opts.idempotent || policy.idempotent(servicePath)) {
+		return c.attemptWithPeer(ctx, payload, sel)
+	}
+
+	if policy.Budget != nil {
+		policy.Budget.deposit()
+	}
+
+	recorder := newPickRecorder(sel)
+	for n := 1; ; n++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.PerAttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.PerAttemptTimeout)
+		}
+		response, err := c.attemptWithPeer(attemptCtx, payload, recorder)
+		cancel()
+
+		if err == nil || n >= policy.MaxAttempts || ctx.Err() != nil {
+			return response, err
+		}
+
+		// an attempt timing out before the call deadline is always retried
+		attemptTimeout := policy.PerAttemptTimeout > 0 && codes.Code(err) == codes.DeadlineExceeded
+		if !attemptTimeout && !policy.retryable(err) {
+			return response, err
+		}
+
+		backoff := policy.backoff(n, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return response, err
+		}
+		if policy.Budget != nil && !policy.Budget.withdraw() {
+			log.Infof("retry budget of %s exhausted, attempt %d failed, %v", servicePath, n, err)
+			return response, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return response, err
+		}
+	}
+}
+
+// attemptWithPeer makes an attempt with its own peer, which is copied to the peer of the call
+func (c *defaultClient) attemptWithPeer(ctx context.Context, payload []byte, sel selector.Selector) (*protocol.Response, error) {
+	callPeer, ok := peer.FromContext(ctx)
+	if !ok {
+		return c.attempt(ctx, payload, sel)
+	}
+
+	p := newAttemptPeer(callPeer)
+	response, err := c.attempt(peer.NewContext(ctx, p), payload, sel)
+	*callPeer = *p
+
+	return response, err
+}
+
+func newAttemptPeer(callPeer *peer.Peer) *peer.Peer {
+	return &peer.Peer{
+		Network:  callPeer.Network,
+		Protocol: callPeer.Protocol,
+	}
+}
+
+// pickRecorder is a Selector which records the nodes picked by the underlying selector
+// and excludes them from the later picks, so that retries and hedges go to other nodes
+type pickRecorder struct {
+	selector.Selector
+
+	mu     sync.Mutex
+	picked []string
+}
+
+func newPickRecorder(sel selector.Selector) *pickRecorder {
+	return &pickRecorder{
+		Selector: sel,
+	}
+}
+
+// Select picks a node which has not been picked if there is one
+func (r *pickRecorder) Select(serviceName string, opts ...selector.Option) (string, error) {
+	r.mu.Lock()
+	exclude := selector.WithExclude(r.picked...)
+	r.mu.Unlock()
+
+	addr, err := r.Selector.Select(serviceName, append(opts, exclude)...)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.picked = append(r.picked, addr)
+	r.mu.Unlock()
+
+	return addr, nil
+}
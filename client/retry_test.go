@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/testdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	// non idempotent methods are not retried
+	ft := &fakeTransport{errs: []error{codes.UnavailableError}}
+	c, opts := newFakeClient("retry1", ft)
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithRetryPolicy(policy))...)
+	assert.Equal(t, codes.UnavailableError, err)
+	assert.Equal(t, 1, len(ft.tried()))
+
+	// each retry goes to another node
+	ft = &fakeTransport{errs: []error{codes.UnavailableError, codes.UnavailableError}}
+	c, opts = newFakeClient("retry2", ft)
+	p := &peer.Peer{}
+	rsp := &testdata.HelloReply{}
+	err = c.Invoke(context.Background(), &testdata.HelloRequest{}, rsp, "/helloworld.Greeter/SayHello",
+		append(opts, WithRetryPolicy(policy), WithIdempotent(), WithPeer(p))...)
+	assert.Nil(t, err)
+	tried := ft.tried()
+	assert.Equal(t, 3, len(tried))
+	assert.NotEqual(t, tried[0], tried[1])
+	assert.NotEqual(t, tried[1], tried[2])
+	assert.NotEqual(t, tried[0], tried[2])
+	assert.Equal(t, tried[2], rsp.Msg)
+	assert.Equal(t, tried[2], p.Addr.String())
+
+	// errors not retryable
+	ft = &fakeTransport{errs: []error{codes.PermissionDeniedError}}
+	c, opts = newFakeClient("retry3", ft)
+	policy.IdempotentMethods = []string{"/helloworld.Greeter/Say*"}
+	err = c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithRetryPolicy(policy))...)
+	assert.Equal(t, codes.PermissionDeniedError, err)
+	assert.Equal(t, 1, len(ft.tried()))
+
+	// business errors are not retried whatever their codes
+	businessErr := codes.New(codes.Unavailable, "business unavailable")
+	ft = &fakeTransport{errs: []error{businessErr}}
+	c, opts = newFakeClient("retry6", ft)
+	err = c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+		append(opts, WithRetryPolicy(policy))...)
+	assert.True(t, errors.Is(err, businessErr))
+	assert.Equal(t, 1, len(ft.tried()))
+}
+
+func TestRetryPerAttemptTimeout(t *testing.T) {
+	// the first node hangs
+	ft := &fakeTransport{
+		delay: func(addr string) time.Duration {
+			if addr == testNodes[0].Address {
+				return time.Second
+			}
+			return 0
+		},
+	}
+	c, opts := newFakeClient("retry4", ft)
+	policy := &RetryPolicy{
+		MaxAttempts:       2,
+		PerAttemptTimeout: 50 * time.Millisecond,
+	}
+
+	for i := 0; i < len(testNodes); i++ {
+		err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello",
+			append(opts, WithRetryPolicy(policy), WithIdempotent(), WithTimeout(500*time.Millisecond))...)
+		assert.Nil(t, err)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(0, 1)
+	policy := &RetryPolicy{
+		MaxAttempts: 3,
+		Budget:      budget,
+	}
+
+	ft := &fakeTransport{errs: []error{codes.UnavailableError, codes.UnavailableError, codes.UnavailableError}}
+	c, opts := newFakeClient("retry5", ft)
+	opts = append(opts, WithRetryPolicy(policy), WithIdempotent())
+
+	// the reserve allows a single retry
+	err := c.Invoke(context.Background(), &testdata.HelloRequest{}, &testdata.HelloReply{}, "/helloworld.Greeter/SayHello", opts...)
+	assert.Equal(t, codes.UnavailableError, err)
+	assert.Equal(t, 2, len(ft.tried()))
+
+	// 10% of 10 calls allows one more retry
+	budget = NewRetryBudget(10, 0)
+	for i := 0; i < 10; i++ {
+		budget.deposit()
+	}
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Jitter:         -1,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1, nil))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2, nil))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(3, nil))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(1, nil)
+		assert.True(t, backoff >= 50*time.Millisecond && backoff <= 150*time.Millisecond)
+	}
+
+	// the server tells when to retry
+	err := codes.UnavailableError.WithDetails(&codes.RetryInfo{RetryDelay: time.Second})
+	assert.Equal(t, time.Second, policy.backoff(1, err))
+}
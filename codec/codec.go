@@ -23,7 +23,7 @@ const Version = 0       //当前版本
 type FrameHeader struct {
 	Magic        uint8  // 魔数  => 硬写到代码里的整数常量
 	Version      uint8  // 版本号 用来支持版本迭代
-	MsgType      uint8  // 消息类型 e.g. :   0x0: 普通消息 ,  0x1: 心跳消息
+	MsgType      uint8  // 消息类型 e.g. :   0x0: 普通消息 ,  0x1: 心跳消息,  0x2: 取消请求
 	ReqType      uint8  // 请求类型 e.g. :   0x0: 一发一收,   0x1: 只发不收,  0x2: 客户端流式请求, 0x3: 服务端流式请求, 0x4: 双向流式请求
 	CompressType uint8  // 是否压缩 :  0x0: 不压缩,  0x1: 压缩
 	StreamID     uint16 // 流 id 为了支持后续流式传输的能力
@@ -31,6 +31,26 @@ type FrameHeader struct {
 	Reserved     uint32 // 4个字节的保留位
 }
 
+// 消息类型
+const (
+	MsgTypeNormal    = 0x0 // 普通消息
+	MsgTypeHeartbeat = 0x1 // 心跳消息
+	MsgTypeCancel    = 0x2 // 取消请求，客户端通知服务端取消正在处理的请求
+)
+
+// GetMsgType returns the message type of a frame
+func GetMsgType(frame []byte) uint8 {
+	if len(frame) < FrameHeadLen {
+		return MsgTypeNormal
+	}
+	return frame[2]
+}
+
+// NewControlFrame builds a frame without body of the given message type, e.g. : a cancel frame
+func NewControlFrame(msgType uint8) ([]byte, error) {
+	return encodeFrame(msgType, nil)
+}
+
 // GetCodec get a Codec by a codec name
 func GetCodec(name string) Codec {
 	if codec, ok := codecMap[name]; ok {
@@ -63,6 +83,10 @@ func RegisterCodec(name string, codec Codec) {
 
 // 编码 => 将一个经过序列化的 request/response 二进制数据，拼接帧头形成一个完整的数据帧
 func (c *defaultCodec) Encode(data []byte) ([]byte, error) {
+	return encodeFrame(MsgTypeNormal, data)
+}
+
+func encodeFrame(msgType uint8, data []byte) ([]byte, error) {
 
 	totalLen := FrameHeadLen + len(data)
 	buffer := bytes.NewBuffer(make([]byte, 0, totalLen))
@@ -71,7 +95,7 @@ func (c *defaultCodec) Encode(data []byte) ([]byte, error) {
 	frame := FrameHeader{
 		Magic:        Magic,
 		Version:      Version,
-		MsgType:      0x0,
+		MsgType:      msgType,
 		ReqType:      0x0,
 		CompressType: 0x0, // 默认不压缩
 		Length:       uint32(len(data)),
@@ -21,3 +21,14 @@ func TestDefaultCodec_Decode(t *testing.T) {
 func TestDefaultCodec_Encode(t *testing.T) {
 
 }
+
+func TestNewControlFrame(t *testing.T) {
+	frame, err := NewControlFrame(MsgTypeCancel)
+	assert.Nil(t, err)
+	assert.Equal(t, FrameHeadLen, len(frame))
+	assert.Equal(t, uint8(MsgTypeCancel), GetMsgType(frame))
+
+	frame, err = DefaultCodec.Encode([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(MsgTypeNormal), GetMsgType(frame))
+}
@@ -1,6 +1,10 @@
 package codes
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 const (
 	OK = 0
@@ -11,6 +15,23 @@ const (
 	ClientCertFail = 401
 )
 
+// canonical codes, retry, circuit breaking and metrics branch on them
+const (
+	Canceled           = 1  // the call was canceled by the caller
+	Unknown            = 2  // the error has no canonical code, e.g. : a business error
+	InvalidArgument    = 3  // the request is invalid whatever the state of the server
+	DeadlineExceeded   = 4  // the deadline expired before the call completed
+	NotFound           = 5  // the requested entity was not found
+	AlreadyExists      = 6  // the entity to be created already exists
+	PermissionDenied   = 7  // the caller is not allowed to call the method
+	ResourceExhausted  = 8  // a quota or a limit is exhausted
+	FailedPrecondition = 9  // the system is not in the state required by the call
+	Aborted            = 10 // the call was aborted, e.g. : a concurrency conflict
+	Unimplemented      = 12 // the method is not implemented by the server
+	Unavailable        = 14 // the server can't be reached, the call can be retried
+	Unauthenticated    = 16 // the caller has no valid credentials
+)
+
 // errorcode type
 const (
 	FrameworkError = 1
@@ -24,6 +45,13 @@ var (
 	ConfigError = NewFrameworkError(ConfigErrorCode,"config error")
 	NetworkNotSupportedError = NewFrameworkError(NetworkNotSupportedErrorCode,"network type not supported")
 	ClientCertFailError = NewFrameworkError(ClientCertFail, "client cert fail")
+
+	CanceledError = NewFrameworkError(Canceled, "canceled")
+	DeadlineExceededError = NewFrameworkError(DeadlineExceeded, "deadline exceeded")
+	PermissionDeniedError = NewFrameworkError(PermissionDenied, "permission denied")
+	ResourceExhaustedError = NewFrameworkError(ResourceExhausted, "resource exhausted")
+	UnimplementedError = NewFrameworkError(Unimplemented, "unimplemented")
+	UnavailableError = NewFrameworkError(Unavailable, "unavailable")
 )
 
 
@@ -32,6 +60,7 @@ type Error struct {
 	Code uint32
 	Type int
 	Message string
+	Details []Detail // optional typed payloads, e.g. : field violations, retry-after
 }
 
 const (
@@ -65,3 +94,56 @@ func New(code uint32, msg string) *Error{
 		Message : msg,
 	}
 }
+
+// Is reports whether target is an Error of the same type and code,
+// so that errors.Is works with the errors rebuilt by the client
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok || e == nil || t == nil {
+		return false
+	}
+	return e.Type == t.Type && e.Code == t.Code
+}
+
+// WithDetails returns a copy of the error with the details appended
+func (e *Error) WithDetails(details ...Detail) *Error {
+	err := *e
+	err.Details = append(append([]Detail(nil), e.Details...), details...)
+	return &err
+}
+
+// FromError returns the Error in the chain of err, ok is false if err is not an Error,
+// in which case a ServerInternalError with the message of err is returned
+func FromError(err error) (e *Error, ok bool) {
+	if err == nil {
+		return nil, true
+	}
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return NewFrameworkError(ServerInternalErrorCode, err.Error()), false
+}
+
+// Code returns the canonical code of err, OK if err is nil. The codes of the business errors
+// belong to the business, Unknown is returned for them, FromError gives their codes.
+func Code(err error) uint32 {
+	if err == nil {
+		return OK
+	}
+	e, _ := FromError(err)
+	if e.Type != FrameworkError {
+		return Unknown
+	}
+	return e.Code
+}
+
+// FromContextError converts a context error into a canonical error, other errors are returned as is
+func FromContextError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceededError
+	case errors.Is(err, context.Canceled):
+		return CanceledError
+	}
+	return err
+}
@@ -1,7 +1,11 @@
 package codes
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -13,3 +17,76 @@ func TestCodes(t *testing.T) {
 	assert.NotNil(t,err)
 	assert.Equal(t, err.Type, BusinuessError)
 }
+
+func TestFromError(t *testing.T) {
+	e, ok := FromError(nil)
+	assert.True(t, ok)
+	assert.Nil(t, e)
+	assert.Equal(t, uint32(OK), Code(nil))
+
+	wrapped := fmt.Errorf("call failed : %w", PermissionDeniedError)
+	e, ok = FromError(wrapped)
+	assert.True(t, ok)
+	assert.Equal(t, PermissionDeniedError, e)
+	assert.Equal(t, uint32(PermissionDenied), Code(wrapped))
+
+	// the codes of the business errors aren't canonical
+	assert.Equal(t, uint32(Unknown), Code(New(Unavailable, "business unavailable")))
+
+	e, ok = FromError(errors.New("boom"))
+	assert.False(t, ok)
+	assert.Equal(t, uint32(ServerInternalErrorCode), e.Code)
+}
+
+func TestErrorIs(t *testing.T) {
+	// an error rebuilt from the wire matches the original
+	rebuilt := NewFrameworkError(DeadlineExceeded, "timeout")
+	assert.True(t, errors.Is(rebuilt, DeadlineExceededError))
+	assert.True(t, errors.Is(fmt.Errorf("wrapped : %w", rebuilt), DeadlineExceededError))
+	assert.False(t, errors.Is(New(DeadlineExceeded, "timeout"), DeadlineExceededError))
+
+	var e *Error
+	assert.True(t, errors.As(fmt.Errorf("wrapped : %w", rebuilt), &e))
+	assert.Equal(t, rebuilt, e)
+}
+
+type quotaFailure struct {
+	Subject string `json:"subject"`
+}
+
+func (*quotaFailure) DetailType() string {
+	return "test.QuotaFailure"
+}
+
+func TestDetails(t *testing.T) {
+	err := New(1001, "invalid request").WithDetails(
+		&BadRequest{FieldViolations: []FieldViolation{{Field: "name", Description: "empty"}}},
+		&RetryInfo{RetryDelay: time.Second},
+		&quotaFailure{Subject: "tenant"},
+	)
+
+	data, e := EncodeDetails(err.Details)
+	assert.Nil(t, e)
+	details, e := DecodeDetails(data)
+	assert.Nil(t, e)
+	assert.Equal(t, 3, len(details))
+	assert.Equal(t, "name", details[0].(*BadRequest).FieldViolations[0].Field)
+	assert.Equal(t, time.Second, details[1].(*RetryInfo).RetryDelay)
+
+	// unregistered types are kept raw, and can be registered later
+	raw, ok := details[2].(*RawDetail)
+	assert.True(t, ok)
+	assert.Equal(t, "test.QuotaFailure", raw.DetailType())
+
+	RegisterDetail(func() Detail { return &quotaFailure{} })
+	details, e = DecodeDetails(data)
+	assert.Nil(t, e)
+	assert.Equal(t, "tenant", details[2].(*quotaFailure).Subject)
+}
+
+func TestFromContextError(t *testing.T) {
+	assert.Equal(t, DeadlineExceededError, FromContextError(context.DeadlineExceeded))
+	assert.Equal(t, CanceledError, FromContextError(context.Canceled))
+	assert.Equal(t, ConfigError, FromContextError(ConfigError))
+	assert.Equal(t, DeadlineExceededError, FromContextError(fmt.Errorf("read : %w", context.DeadlineExceeded)))
+}
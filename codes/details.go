@@ -0,0 +1,116 @@
+package codes
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Detail is a typed payload attached to an Error, e.g. : field violations, retry-after.
+// Details are sent to the client in json, the type name identifies the Go type to decode into.
+type Detail interface {
+	DetailType() string
+}
+
+var (
+	detailMu    sync.RWMutex
+	detailTypes = make(map[string]func() Detail)
+)
+
+func init() {
+	RegisterDetail(func() Detail { return &BadRequest{} })
+	RegisterDetail(func() Detail { return &RetryInfo{} })
+}
+
+// RegisterDetail registers a detail type, so that it can be decoded by the client,
+// newDetail returns a pointer to a new empty detail
+func RegisterDetail(newDetail func() Detail) {
+	detailMu.Lock()
+	defer detailMu.Unlock()
+	detailTypes[newDetail().DetailType()] = newDetail
+}
+
+// FieldViolation describes a single invalid request field
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// BadRequest describes the invalid fields of a request
+type BadRequest struct {
+	FieldViolations []FieldViolation `json:"field_violations"`
+}
+
+// DetailType returns the type name of BadRequest
+func (*BadRequest) DetailType() string {
+	return "gorpc.BadRequest"
+}
+
+// RetryInfo tells the client how long to wait before retrying
+type RetryInfo struct {
+	RetryDelay time.Duration `json:"retry_delay"`
+}
+
+// DetailType returns the type name of RetryInfo
+func (*RetryInfo) DetailType() string {
+	return "gorpc.RetryInfo"
+}
+
+// RawDetail is a detail whose type is not registered on the client, it keeps the json value
+type RawDetail struct {
+	Type  string
+	Value json.RawMessage
+}
+
+// DetailType returns the type name of the undecoded detail
+func (r *RawDetail) DetailType() string {
+	return r.Type
+}
+
+type wireDetail struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// EncodeDetails encodes the details into the wire format
+func EncodeDetails(details []Detail) ([]byte, error) {
+	wire := make([]wireDetail, 0, len(details))
+	for _, d := range details {
+		if raw, ok := d.(*RawDetail); ok {
+			wire = append(wire, wireDetail{Type: raw.Type, Value: raw.Value})
+			continue
+		}
+		value, err := json.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		wire = append(wire, wireDetail{Type: d.DetailType(), Value: value})
+	}
+	return json.Marshal(wire)
+}
+
+// DecodeDetails decodes the details from the wire format, details of unregistered types are kept as RawDetail
+func DecodeDetails(data []byte) ([]Detail, error) {
+	var wire []wireDetail
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return nil, err
+	}
+
+	detailMu.RLock()
+	defer detailMu.RUnlock()
+
+	details := make([]Detail, 0, len(wire))
+	for _, w := range wire {
+		newDetail, ok := detailTypes[w.Type]
+		if !ok {
+			details = append(details, &RawDetail{Type: w.Type, Value: w.Value})
+			continue
+		}
+		d := newDetail()
+		if err := json.Unmarshal(w.Value, d); err != nil {
+			return nil, err
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
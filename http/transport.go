@@ -7,6 +7,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/peer"
 	"github.com/lubanproj/gorpc/transport"
 )
 
@@ -50,7 +51,7 @@ func (s *httpServerTransport) ListenAndServe(ctx context.Context, opts ...transp
 	}
 
 
-	s.Server.Handler = DefaultRouter
+	s.Server.Handler = s.withPeer(DefaultRouter)
 	go func() {
 		if err = s.Server.Serve(lis); err != nil {
 			log.Errorf("http serve error, %v", err)
@@ -60,6 +61,21 @@ func (s *httpServerTransport) ListenAndServe(ctx context.Context, opts ...transp
 	return nil
 }
 
+// withPeer attaches the peer information to the request context
+func (s *httpServerTransport) withPeer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &peer.Peer{
+			Addr:     peer.NewAddr(s.opts.Network, r.RemoteAddr),
+			Network:  s.opts.Network,
+			Protocol: "http",
+		}
+		if localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			p.LocalAddr = localAddr
+		}
+		handler.ServeHTTP(w, r.WithContext(peer.NewContext(r.Context(), p)))
+	})
+}
+
 // HandlerFunc is an adapter which allows the usage of an http handler
 // request handle.
 func HandleFunc(method, path string, handler func(http.ResponseWriter, *http.Request)) error {
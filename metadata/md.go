@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// ReservedPrefix is the key namespace of the framework, e.g. : gorpc-timeout.
+// Reserved keys are dropped from MD and can only be read and written through the raw maps.
+const ReservedPrefix = "gorpc-"
+
+// multiValuePrefix is the reserved key prefix carrying all the values of a multi-value key,
+// the key itself carries the first value for the readers of the raw maps
+const multiValuePrefix = ReservedPrefix + "mv-"
+
+// IsReserved returns whether the key belongs to the framework key namespace
+func IsReserved(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), ReservedPrefix)
+}
+
+// MD is the metadata of a call, keys are case-insensitive and may have multiple values
+type MD map[string][]string
+
+// New creates a MD from a key-value map
+func New(m map[string]string) MD {
+	md := make(MD, len(m))
+	for k, v := range m {
+		md.Set(k, v)
+	}
+	return md
+}
+
+// Get returns the values of the key
+func (md MD) Get(key string) []string {
+	return md[strings.ToLower(key)]
+}
+
+// Set replaces the values of the key
+func (md MD) Set(key string, vals ...string) {
+	if len(vals) == 0 {
+		return
+	}
+	md[strings.ToLower(key)] = vals
+}
+
+// Append adds the values to the key
+func (md MD) Append(key string, vals ...string) {
+	if len(vals) == 0 {
+		return
+	}
+	key = strings.ToLower(key)
+	md[key] = append(md[key], vals...)
+}
+
+// Delete removes the key
+func (md MD) Delete(key string) {
+	delete(md, strings.ToLower(key))
+}
+
+// Copy returns a deep copy of md
+func (md MD) Copy() MD {
+	out := make(MD, len(md))
+	for k, v := range md {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// FromIncomingContext returns a copy of the metadata received by the server
+func FromIncomingContext(ctx context.Context) MD {
+	return decode(ServerMetadata(ctx))
+}
+
+// FromOutgoingContext returns a copy of the metadata to be sent by the client
+func FromOutgoingContext(ctx context.Context) MD {
+	return decode(clientRaw(ctx))
+}
+
+// NewOutgoingContext creates a new context with md to be sent by the client,
+// it replaces the metadata already attached to ctx
+func NewOutgoingContext(ctx context.Context, md MD) context.Context {
+	return WithClientMetadata(ctx, encode(md))
+}
+
+type forwardMD struct{}
+
+// NewForwardContext creates a new context in which the incoming values of the keys
+// are forwarded to the outgoing calls made with it, e.g. : request-id, tenant, baggage
+func NewForwardContext(ctx context.Context, keys []string) context.Context {
+	incoming := FromIncomingContext(ctx)
+
+	md := make(MD)
+	for _, key := range keys {
+		md.Set(key, incoming.Get(key)...)
+	}
+	if len(md) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, forwardMD{}, encode(md))
+}
+
+// OutgoingMetadata returns a copy of the raw metadata sent by the client, which is the forwarded
+// metadata overlaid by the client metadata. A key set by the client replaces all the forwarded values.
+func OutgoingMetadata(ctx context.Context) map[string][]byte {
+	md := make(map[string][]byte)
+
+	if fwd, ok := ctx.Value(forwardMD{}).(map[string][]byte); ok {
+		for k, v := range fwd {
+			md[k] = v
+		}
+	}
+
+	client := clientRaw(ctx)
+	for k := range client {
+		key := strings.TrimPrefix(strings.ToLower(k), multiValuePrefix)
+		delete(md, key)
+		delete(md, multiValuePrefix+key)
+	}
+	for k, v := range client {
+		md[k] = v
+	}
+
+	return md
+}
+
+// encode converts md into the raw wire format
+func encode(md MD) map[string][]byte {
+	raw := make(map[string][]byte, len(md))
+	for k, vals := range md {
+		if len(vals) == 0 || IsReserved(k) {
+			continue
+		}
+		raw[k] = []byte(vals[0])
+		if len(vals) > 1 {
+			raw[multiValuePrefix+k] = encodeValues(vals)
+		}
+	}
+	return raw
+}
+
+// decode converts the raw wire format into md, reserved keys are dropped
+func decode(raw map[string][]byte) MD {
+	md := make(MD, len(raw))
+	for k, v := range raw {
+		key := strings.ToLower(k)
+		if !strings.HasPrefix(key, multiValuePrefix) {
+			continue
+		}
+		if vals, err := decodeValues(v); err == nil {
+			md[strings.TrimPrefix(key, multiValuePrefix)] = vals
+		}
+	}
+	for k, v := range raw {
+		key := strings.ToLower(k)
+		if IsReserved(key) {
+			continue
+		}
+		if _, ok := md[key]; !ok {
+			md[key] = []string{string(v)}
+		}
+	}
+	return md
+}
+
+// encodeValues encodes the values as a sequence of uvarint length prefixed bytes
+func encodeValues(vals []string) []byte {
+	var buf []byte
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	for _, v := range vals {
+		n := binary.PutUvarint(lenBuf, uint64(len(v)))
+		buf = append(buf, lenBuf[:n]...)
+		buf = append(buf, v...)
+	}
+	return buf
+}
+
+func decodeValues(buf []byte) ([]string, error) {
+	var vals []string
+	for len(buf) > 0 {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < l {
+			return nil, errors.New("metadata: invalid multi-value encoding")
+		}
+		vals = append(vals, string(buf[n:n+int(l)]))
+		buf = buf[n+int(l):]
+	}
+	return vals, nil
+}
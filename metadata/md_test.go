@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMD(t *testing.T) {
+	md := New(map[string]string{"Request-ID": "1"})
+	assert.Equal(t, []string{"1"}, md.Get("request-id"))
+
+	md.Append("baggage", "a=1")
+	md.Append("BAGGAGE", "b=2")
+	assert.Equal(t, []string{"a=1", "b=2"}, md.Get("Baggage"))
+
+	md.Set("baggage", "c=3")
+	assert.Equal(t, []string{"c=3"}, md.Get("baggage"))
+
+	cp := md.Copy()
+	cp.Delete("Request-Id")
+	assert.Nil(t, cp.Get("request-id"))
+	assert.Equal(t, []string{"1"}, md.Get("request-id"))
+}
+
+func TestOutgoingContext(t *testing.T) {
+	md := New(map[string]string{"tenant": "t1", TimeoutKey: "1"})
+	md.Append("baggage", "a=1", "b=\x002")
+	ctx := NewOutgoingContext(context.Background(), md)
+
+	// reserved keys can't be set through MD
+	raw := OutgoingMetadata(ctx)
+	_, ok := raw[TimeoutKey]
+	assert.False(t, ok)
+	// readers of the raw map see the first value
+	assert.Equal(t, "a=1", string(raw["baggage"]))
+
+	out := FromOutgoingContext(ctx)
+	assert.Equal(t, []string{"t1"}, out.Get("tenant"))
+	assert.Equal(t, []string{"a=1", "b=\x002"}, out.Get("baggage"))
+	assert.Nil(t, out.Get(TimeoutKey))
+}
+
+func TestForwardContext(t *testing.T) {
+	incoming := NewOutgoingContext(context.Background(), MD{
+		"request-id": {"42"},
+		"baggage":    {"a=1", "b=2"},
+		"secret":     {"s"},
+	})
+	ctx := WithServerMetadata(context.Background(), OutgoingMetadata(incoming))
+	ctx = NewForwardContext(ctx, []string{"Request-ID", "baggage", "tenant"})
+
+	out := decode(OutgoingMetadata(ctx))
+	assert.Equal(t, []string{"42"}, out.Get("request-id"))
+	assert.Equal(t, []string{"a=1", "b=2"}, out.Get("baggage"))
+	assert.Nil(t, out.Get("secret"))
+
+	// the client metadata replaces the forwarded values
+	ctx = NewOutgoingContext(ctx, MD{"baggage": {"c=3"}})
+	out = decode(OutgoingMetadata(ctx))
+	assert.Equal(t, []string{"42"}, out.Get("request-id"))
+	assert.Equal(t, []string{"c=3"}, out.Get("baggage"))
+}
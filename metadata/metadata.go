@@ -1,6 +1,20 @@
 package metadata
 
-import "context"
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// TimeoutKey is the reserved key carrying the remaining time budget of the caller
+// in milliseconds, the server derives the handler context deadline from it
+const TimeoutKey = "gorpc-timeout"
+
+// reserved response keys carrying the type and the details of the error returned by the server
+const (
+	ErrorTypeKey    = "gorpc-error-type"
+	ErrorDetailsKey = "gorpc-error-details"
+)
 
 type clientMD struct {}
 type serverMD struct {}
@@ -9,14 +23,22 @@ type clientMetadata map[string][]byte
 
 type serverMetadata map[string][]byte
 
-// ClientMetadata creates a new context with key-value pairs attached.
+// ClientMetadata returns the raw metadata to be sent by the client. If ctx has none, an empty map
+// which is not attached to any context is returned, it must be attached by WithClientMetadata.
+//
+// Deprecated: the changes made to the map returned are not seen by the calls made with ctx if ctx has
+// no metadata, and are seen by all the contexts sharing the map otherwise. Use FromOutgoingContext
+// to read the metadata sent by the client, and NewOutgoingContext to set it.
 func ClientMetadata(ctx context.Context) clientMetadata {
+	return clientRaw(ctx)
+}
+
+// clientRaw returns the raw metadata attached to ctx to be sent by the client, an empty map if ctx has none
+func clientRaw(ctx context.Context) clientMetadata {
 	if md, ok := ctx.Value(clientMD{}).(clientMetadata); ok {
 		return md
 	}
-	md := make(map[string][]byte)
-	WithClientMetadata(ctx, md)
-	return md
+	return make(map[string][]byte)
 }
 
 // WithClientMetadata creates a new context with the specified metadata
@@ -24,14 +46,13 @@ func WithClientMetadata(ctx context.Context, metadata map[string][]byte) context
 	return context.WithValue(ctx, clientMD{}, clientMetadata(metadata))
 }
 
-// ServerMetadata creates a new context with key-value pairs attached.
+// ServerMetadata returns the raw metadata received by the server. If ctx has none, an empty map
+// which is not attached to any context is returned.
 func ServerMetadata(ctx context.Context) serverMetadata {
 	if md, ok := ctx.Value(serverMD{}).(serverMetadata); ok {
 		return md
 	}
-	md := make(map[string][]byte)
-	WithServerMetadata(ctx, md)
-	return md
+	return make(map[string][]byte)
 }
 
 // WithServerMetadata creates a new context with the specified metadata
@@ -39,3 +60,47 @@ func WithServerMetadata(ctx context.Context, metadata map[string][]byte) context
 	return context.WithValue(ctx, serverMD{}, serverMetadata(metadata))
 }
 
+
+type responseMD struct {}
+
+// responseMetadata collects the metadata sent back to the client with the response
+type responseMetadata struct {
+	mu sync.Mutex
+	md map[string][]byte
+}
+
+// NewResponseContext creates a new context in which the response metadata can be set by SetHeader,
+// the returned func gets a copy of the metadata set so far. It is called by the server transport.
+func NewResponseContext(ctx context.Context) (context.Context, func() map[string][]byte) {
+	rmd := &responseMetadata{
+		md: make(map[string][]byte),
+	}
+	get := func() map[string][]byte {
+		rmd.mu.Lock()
+		defer rmd.mu.Unlock()
+
+		md := make(map[string][]byte, len(rmd.md))
+		for k, v := range rmd.md {
+			md[k] = v
+		}
+		return md
+	}
+	return context.WithValue(ctx, responseMD{}, rmd), get
+}
+
+// SetHeader sets the metadata sent to the client with the response, e.g. : server timing, pagination cursors.
+// A later value replaces the earlier one of the same key. It can be called by handlers and server interceptors.
+func SetHeader(ctx context.Context, md map[string][]byte) error {
+	rmd, ok := ctx.Value(responseMD{}).(*responseMetadata)
+	if !ok {
+		return errors.New("metadata: no response in context")
+	}
+
+	rmd.mu.Lock()
+	defer rmd.mu.Unlock()
+
+	for k, v := range md {
+		rmd.md[k] = v
+	}
+	return nil
+}
@@ -26,4 +26,20 @@ func TestServerMetadata(t *testing.T) {
 	newCtx := WithServerMetadata(ctx, md)
 	md = ServerMetadata(newCtx)
 	assert.Equal(t, string(md["test"]), "test_server_metadata")
-}
\ No newline at end of file
+}
+
+func TestSetHeader(t *testing.T) {
+	assert.NotNil(t, SetHeader(context.Background(), map[string][]byte{"k": []byte("v")}))
+
+	ctx, get := NewResponseContext(context.Background())
+	assert.Nil(t, SetHeader(ctx, map[string][]byte{"k": []byte("v1"), "cursor": []byte("10")}))
+	assert.Nil(t, SetHeader(ctx, map[string][]byte{"k": []byte("v2")}))
+
+	md := get()
+	assert.Equal(t, "v2", string(md["k"]))
+	assert.Equal(t, "10", string(md["cursor"]))
+
+	// the returned metadata is a copy
+	md["k"] = []byte("changed")
+	assert.Equal(t, "v2", string(get()["k"]))
+}
@@ -64,6 +64,33 @@ func TestWithSelectorSvrAddr(t *testing.T) {
 	assert.Equal(t, "", serverops.selectorSvrAddr)
 }
 
+func TestWithWeight(t *testing.T) {
+	var serverops ServerOptions
+	fServerops := WithWeight(10)
+	fServerops(&serverops)
+	assert.Equal(t, 10, serverops.weight)
+}
+
+func TestWithTags(t *testing.T) {
+	var serverops ServerOptions
+	WithTags("canary")(&serverops)
+	WithTags("v1")(&serverops)
+	assert.Equal(t, []string{"canary", "v1"}, serverops.tags)
+}
+
+func TestWithMeta(t *testing.T) {
+	var serverops ServerOptions
+	WithMeta("version", "v1.2.0")(&serverops)
+	WithMeta("zone", "us-east-1a")(&serverops)
+	assert.Equal(t, map[string]string{"version": "v1.2.0", "zone": "us-east-1a"}, serverops.meta)
+}
+
+func TestWithHealthCheck(t *testing.T) {
+	var serverops ServerOptions
+	WithHealthCheck("tcp")(&serverops)
+	assert.Equal(t, "tcp", serverops.healthCheck)
+}
+
 func TestWithPlugin(t *testing.T) {
 	var serverops ServerOptions
 	fServerops := WithPlugin("test")
@@ -102,3 +129,31 @@ func TestWithTracingSpanName(t *testing.T) {
 	fServerops(&serverops)
 	assert.Equal(t, "", serverops.tracingSpanName)
 }
+
+func TestWithConnLimits(t *testing.T) {
+	var serverops ServerOptions
+	WithAllowCIDRs("10.0.0.0/8")(&serverops)
+	WithDenyCIDRs("10.1.0.0/16")(&serverops)
+	WithMaxConns(100)(&serverops)
+	WithMaxConnsPerIP(10)(&serverops)
+	WithIdleTimeout(time.Minute)(&serverops)
+	WithReadTimeout(time.Second)(&serverops)
+	WithWriteTimeout(2 * time.Second)(&serverops)
+	assert.Equal(t, time.Second, serverops.readTimeout)
+	assert.Equal(t, 2*time.Second, serverops.writeTimeout)
+	assert.Equal(t, []string{"10.0.0.0/8"}, serverops.allowCIDRs)
+	assert.Equal(t, []string{"10.1.0.0/16"}, serverops.denyCIDRs)
+	assert.Equal(t, 100, serverops.maxConns)
+	assert.Equal(t, 10, serverops.maxConnsPerIP)
+
+	// like the transport options, a later call replaces the list
+	WithAllowCIDRs("192.168.0.0/16", "127.0.0.1")(&serverops)
+	assert.Equal(t, []string{"192.168.0.0/16", "127.0.0.1"}, serverops.allowCIDRs)
+	assert.Equal(t, time.Minute, serverops.idleTimeout)
+}
+
+func TestWithForwardMetadata(t *testing.T) {
+	var serverops ServerOptions
+	WithForwardMetadata("request-id", "tenant")(&serverops)
+	assert.Equal(t, []string{"request-id", "tenant"}, serverops.forwardMetadata)
+}
@@ -3,6 +3,7 @@ package gorpc
 import (
 	"time"
 
+	"github.com/lubanproj/gorpc/auth"
 	"github.com/lubanproj/gorpc/interceptor"
 )
 
@@ -14,11 +15,27 @@ type ServerOptions struct {
 	timeout           time.Duration // 超时时间 timeout
 	serializationType string        // 序列化类型 , default: proto
 
-	selectorSvrAddr string   // service discovery server address, required when using the third-party service discovery plugin
-	tracingSvrAddr  string   // tracing plugin server address, required when using the third-party tracing plugin
-	tracingSpanName string   // tracing span name, required when using the third-party tracing plugin
-	pluginNames     []string // plugin name
+	selectorSvrAddr string            // service discovery server address, required when using the third-party service discovery plugin
+	weight          int               // weight of the server registered to the service discovery, used by the weighted balancers
+	tags            []string          // tags of the server registered to the service discovery
+	meta            map[string]string // meta of the server registered to the service discovery, e.g. : version、zone
+	healthCheck     string            // health check of the server registered to the service discovery, ttl or tcp
+	tracingSvrAddr  string            // tracing plugin server address, required when using the third-party tracing plugin
+	tracingSpanName string            // tracing span name, required when using the third-party tracing plugin
+	pluginNames     []string          // plugin name
 	interceptors    []interceptor.ServerInterceptor
+
+	allowCIDRs    []string      // only the client ips within these CIDRs are served if not empty
+	denyCIDRs     []string      // the client ips within these CIDRs are rejected
+	maxConns      int           // max number of concurrent connections, 0 means no limit
+	maxConnsPerIP int           // max number of concurrent connections per client ip, 0 means no limit
+	idleTimeout   time.Duration // a connection without any request during this time will be closed
+	readTimeout   time.Duration // max duration for reading a request once it arrives
+	writeTimeout  time.Duration // max duration for writing a response
+
+	transportAuth auth.TransportAuth // handshakes with the clients, e.g. : tls
+
+	forwardMetadata []string // incoming metadata keys forwarded to the outgoing calls made by handlers
 }
 
 type ServerOption func(*ServerOptions)
@@ -59,6 +76,39 @@ func WithSelectorSvrAddr(addr string) ServerOption {
 	}
 }
 
+// WithWeight returns a ServerOption which sets the weight of the server registered to the service discovery
+func WithWeight(weight int) ServerOption {
+	return func(o *ServerOptions) {
+		o.weight = weight
+	}
+}
+
+// WithTags returns a ServerOption which adds tags to the server registered to the service discovery
+func WithTags(tags ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// WithMeta returns a ServerOption which sets a meta of the server registered to the service discovery,
+// e.g. : WithMeta("version", "v1.2.0")、WithMeta("zone", "us-east-1a")
+func WithMeta(key, value string) ServerOption {
+	return func(o *ServerOptions) {
+		if o.meta == nil {
+			o.meta = make(map[string]string)
+		}
+		o.meta[key] = value
+	}
+}
+
+// WithHealthCheck returns a ServerOption which sets the health check of the server registered to the
+// service discovery : "ttl", the server keeps its check passing, which is the default, or "tcp"
+func WithHealthCheck(healthCheck string) ServerOption {
+	return func(o *ServerOptions) {
+		o.healthCheck = healthCheck
+	}
+}
+
 func WithPlugin(pluginName ...string) ServerOption {
 	return func(o *ServerOptions) {
 		o.pluginNames = append(o.pluginNames, pluginName...)
@@ -82,3 +132,57 @@ func WithTracingSpanName(name string) ServerOption {
 		o.tracingSpanName = name
 	}
 }
+
+func WithAllowCIDRs(cidrs ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.allowCIDRs = cidrs
+	}
+}
+
+func WithDenyCIDRs(cidrs ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.denyCIDRs = cidrs
+	}
+}
+
+func WithMaxConns(maxConns int) ServerOption {
+	return func(o *ServerOptions) {
+		o.maxConns = maxConns
+	}
+}
+
+func WithMaxConnsPerIP(maxConnsPerIP int) ServerOption {
+	return func(o *ServerOptions) {
+		o.maxConnsPerIP = maxConnsPerIP
+	}
+}
+
+func WithIdleTimeout(idleTimeout time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.idleTimeout = idleTimeout
+	}
+}
+
+func WithReadTimeout(readTimeout time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.readTimeout = readTimeout
+	}
+}
+
+func WithWriteTimeout(writeTimeout time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.writeTimeout = writeTimeout
+	}
+}
+
+func WithTransportAuth(transportAuth auth.TransportAuth) ServerOption {
+	return func(o *ServerOptions) {
+		o.transportAuth = transportAuth
+	}
+}
+
+func WithForwardMetadata(keys ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.forwardMetadata = keys
+	}
+}
@@ -0,0 +1,54 @@
+// Package peer defines the information about the other side of an RPC,
+// it is filled by the transport layer and can be read from the context
+package peer
+
+import (
+	"context"
+	"net"
+
+	"github.com/lubanproj/gorpc/auth"
+)
+
+// Peer contains the information of the peer for an RPC
+type Peer struct {
+	Addr      net.Addr      // remote address, on the client side it's the node picked by the selector
+	LocalAddr net.Addr      // local address
+	Network   string        // network type, e.g. : tcp、udp
+	Protocol  string        // transport protocol, e.g. : proto、http
+	AuthInfo  auth.AuthInfo // authentication information of the transport, nil if not authenticated
+}
+
+type peerKey struct{}
+
+// NewContext creates a new context with peer information attached
+func NewContext(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, p)
+}
+
+// FromContext returns the peer information in ctx if it exists
+func FromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
+
+// addr is a net.Addr built from a network and an address string
+type addr struct {
+	network string
+	address string
+}
+
+func (a *addr) Network() string {
+	return a.network
+}
+
+func (a *addr) String() string {
+	return a.address
+}
+
+// NewAddr builds a net.Addr from a network type and an address such as 127.0.0.1:8000
+func NewAddr(network, address string) net.Addr {
+	return &addr{
+		network: network,
+		address: address,
+	}
+}
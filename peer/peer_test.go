@@ -0,0 +1,24 @@
+package peer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	p := &Peer{
+		Addr:    NewAddr("tcp", "127.0.0.1:8000"),
+		Network: "tcp",
+	}
+	ctx := NewContext(context.Background(), p)
+	got, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+	assert.Equal(t, "127.0.0.1:8000", got.Addr.String())
+	assert.Equal(t, "tcp", got.Addr.Network())
+}
@@ -1,17 +1,20 @@
 package consul
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/lubanproj/gorpc/plugin"
+	"github.com/lubanproj/gorpc/registry"
 	"github.com/lubanproj/gorpc/selector"
 )
 
-// Consul implements the server discovery specification
+// Consul implements the server discovery specification, it's a registry.Registry
 type Consul struct {
 	opts         *plugin.Options
 	client       *api.Client
@@ -19,12 +22,27 @@ type Consul struct {
 	balancerName string // load balancing mode, including random, polling, weighted polling, consistent hash, etc
 	writeOptions *api.WriteOptions
 	queryOptions *api.QueryOptions
+
+	watchMu       sync.Mutex
+	watchers      map[string]*watcher // service name -> watcher of its nodes
+	ctx           context.Context     // context of the blocking queries of the watchers
+	cancel        context.CancelFunc  // stops the watchers, their blocking queries in flight are canceled
+	waitTime      time.Duration       // max duration of a blocking query, defaultWaitTime if 0
+	retryInterval time.Duration       // interval of the queries while consul is unreachable, defaultRetryInterval if 0
+
+	subscribers registry.Watchers // watchers of the instances of the services
+
+	registerMu sync.Mutex
+	checks     map[string]bool // ids of the ttl checks of the instances registered, kept passing
+	stop       chan struct{}   // closed to stop keeping the ttl checks passing
+	checkTTL   time.Duration   // ttl of the checks of the instances registered, defaultCheckTTL if 0
 }
 
 const Name = "consul"
 
 func init() {
-	plugin.Register(Name, ConsulSvr)
+	registry.RegisterRegistry(Name, ConsulSvr)
+	// the balancer of the selector of ConsulSvr is kept
 	selector.RegisterSelector(Name, ConsulSvr)
 }
 
@@ -52,57 +70,27 @@ func (c *Consul) InitConfig() error {
 	return nil
 }
 
-// 通过一个服务名去获取服务列表
-func (c *Consul) Resolve(serviceName string) ([]*selector.Node, error) {
-
-	// 从 consul k-v 存储中获取服务列表
-	pairs, _, err := c.client.KV().List(serviceName, nil)
-	if err != nil {
-		return nil, err
+// 通过一个服务名去获取服务列表, the instances are served from a local cache kept fresh by consul blocking queries
+func (c *Consul) Resolve(serviceName string) ([]*registry.Instance, error) {
+	if c.client == nil {
+		return nil, errors.New("consul is not initialized")
 	}
-
-	if len(pairs) == 0 {
-		return nil, fmt.Errorf("no services find in path : %s", serviceName)
-	}
-	var nodes []*selector.Node
-	for _, pair := range pairs {
-		nodes = append(nodes, &selector.Node{
-			Key:   pair.Key,
-			Value: pair.Value,
-		})
-	}
-	return nodes, nil
+	return c.watch(serviceName).resolve()
 }
 
-// implements selector Select method 服务发现的过程
-func (c *Consul) Select(serviceName string) (string, error) {
-
-	// 通过服务名获取可用节点
-	nodes, err := c.Resolve(serviceName)
-
-	if nodes == nil || len(nodes) == 0 || err != nil {
-		return "", err
+// Watch returns a Watcher of the passing instances of the service
+func (c *Consul) Watch(serviceName string) (registry.Watcher, error) {
+	if c.client == nil {
+		return nil, errors.New("consul is not initialized")
 	}
-
-	//负载均衡实现
-	balancer := selector.GetBalancer(c.balancerName)
-	node := balancer.Balance(serviceName, nodes)
-
-	if node == nil {
-		return "", fmt.Errorf("no services find in %s", serviceName)
-	}
-
-	return parseAddrFromNode(node)
+	w := c.subscribers.Watch(serviceName)
+	c.watch(serviceName)
+	return w, nil
 }
 
-func parseAddrFromNode(node *selector.Node) (string, error) {
-	if node.Key == "" {
-		return "", errors.New("addr is empty")
-	}
-
-	strs := strings.Split(node.Key, "/")
-
-	return strs[len(strs)-1], nil
+// implements selector Select method 服务发现的过程
+func (c *Consul) Select(serviceName string, opts ...selector.Option) (string, error) {
+	return registry.NewSelector(c, c.balancerName).Select(serviceName, opts...)
 }
 
 func (c *Consul) Init(opts ...plugin.Option) error {
@@ -123,15 +111,14 @@ func (c *Consul) Init(opts ...plugin.Option) error {
 
 	// 服务注册
 	for _, serviceName := range c.opts.Services {
-		nodeName := fmt.Sprintf("%s/%s", serviceName, c.opts.SvrAddr)
-
-		kvPair := &api.KVPair{
-			Key:   nodeName,
-			Value: []byte(c.opts.SvrAddr),
-			Flags: api.LockFlagValue,
+		instance := &registry.Instance{
+			ServiceName: serviceName,
+			Address:     c.opts.SvrAddr,
+			Weight:      c.opts.Weight,
+			Tags:        c.opts.Tags,
+			Meta:        c.opts.Meta,
 		}
-
-		if _, err := c.client.KV().Put(kvPair, c.writeOptions); err != nil {
+		if err := c.Register(instance); err != nil {
 			return err
 		}
 	}
@@ -3,6 +3,8 @@ package consul
 import (
 	"testing"
 
+	"github.com/hashicorp/consul/api"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,3 +12,24 @@ func TestInit(t *testing.T) {
 	err := Init("localhost:8500")
 	assert.Nil(t,err)
 }
+
+func TestParseEntries(t *testing.T) {
+	entries := []*api.ServiceEntry{
+		{
+			Node:    &api.Node{Address: "10.0.0.1"},
+			Service: &api.AgentService{ID: "a", Port: 8000, Weights: api.AgentWeights{Passing: 10}, Meta: map[string]string{"zone": "us-east-1a"}},
+		},
+		{
+			Node:    &api.Node{Address: "10.0.0.1"},
+			Service: &api.AgentService{ID: "b", Address: "10.0.0.2", Port: 8000, Weights: api.AgentWeights{Passing: 3}},
+		},
+	}
+
+	nodes := parseEntries(entries)
+	assert.Equal(t, 2, len(nodes))
+	// the address of the node is used if the service has none
+	assert.Equal(t, "10.0.0.1:8000", nodes[0].Address)
+	assert.Equal(t, 10, nodes[0].Weight)
+	assert.Equal(t, "10.0.0.2:8000", nodes[1].Address)
+	assert.Equal(t, 3, nodes[1].Weight)
+}
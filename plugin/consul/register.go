@@ -0,0 +1,168 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/plugin"
+	"github.com/lubanproj/gorpc/registry"
+	"github.com/lubanproj/gorpc/selector"
+)
+
+const (
+	// defaultCheckTTL is the ttl of the check of a service, the server marks it passing three times per ttl
+	defaultCheckTTL = 10 * time.Second
+
+	// tcpCheckInterval is the interval of the tcp checks dialing the server
+	tcpCheckInterval = 10 * time.Second
+
+	// deregisterCriticalAfter is how long a service stays critical before consul deregisters it,
+	// so that the servers which crashed without deregistering are removed
+	deregisterCriticalAfter = time.Minute
+)
+
+// Register registers an instance as a consul agent service with a health check, its ttl check
+// is kept passing until it's deregistered
+func (c *Consul) Register(instance *registry.Instance) error {
+	if c.client == nil {
+		return errors.New("consul is not initialized")
+	}
+
+	registration, err := c.registration(instance)
+	if err != nil {
+		return err
+	}
+	if err := c.client.Agent().ServiceRegister(registration); err != nil {
+		return err
+	}
+
+	if registration.Check.TTL == "" {
+		return nil
+	}
+
+	c.registerMu.Lock()
+	defer c.registerMu.Unlock()
+
+	if c.checks == nil {
+		c.checks = make(map[string]bool)
+	}
+	c.checks[registration.Check.CheckID] = true
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+		go c.keepAlive(c.stop)
+	}
+
+	return nil
+}
+
+// Deregister removes an instance from consul, the server deregisters its instances when it closes
+func (c *Consul) Deregister(instance *registry.Instance) error {
+	if c.client == nil {
+		return errors.New("consul is not initialized")
+	}
+
+	id := serviceID(instance)
+
+	c.registerMu.Lock()
+	delete(c.checks, checkID(id))
+	if len(c.checks) == 0 && c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.registerMu.Unlock()
+
+	return c.client.Agent().ServiceDeregister(id)
+}
+
+func serviceID(instance *registry.Instance) string {
+	return fmt.Sprintf("%s-%s", instance.ServiceName, instance.Address)
+}
+
+func checkID(serviceID string) string {
+	return "service:" + serviceID
+}
+
+func (c *Consul) registration(instance *registry.Instance) (*api.AgentServiceRegistration, error) {
+	host, portStr, err := net.SplitHostPort(instance.Address)
+	if err != nil {
+		return nil, fmt.Errorf("consul register error, invalid server address %s, %v", instance.Address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("consul register error, invalid server port %s, %v", portStr, err)
+	}
+
+	id := serviceID(instance)
+
+	// the weight is kept in the weights of the service only, which the health entries return
+	weight := instance.Weight
+	if weight <= 0 {
+		weight = selector.DefaultWeight
+	}
+
+	check := &api.AgentServiceCheck{
+		CheckID:                        checkID(id),
+		Name:                           instance.ServiceName + " health check",
+		DeregisterCriticalServiceAfter: deregisterCriticalAfter.String(),
+	}
+	if c.opts.HealthCheck == plugin.HealthCheckTCP {
+		check.TCP = instance.Address
+		check.Interval = tcpCheckInterval.String()
+		check.Timeout = time.Second.String()
+	} else {
+		check.TTL = c.ttl().String()
+		check.Status = api.HealthPassing
+	}
+
+	return &api.AgentServiceRegistration{
+		ID:      id,
+		Name:    instance.ServiceName,
+		Address: host,
+		Port:    port,
+		Tags:    instance.Tags,
+		Meta:    instance.Meta,
+		Weights: &api.AgentWeights{
+			Passing: weight,
+			Warning: 1,
+		},
+		Check: check,
+	}, nil
+}
+
+func (c *Consul) ttl() time.Duration {
+	if c.checkTTL > 0 {
+		return c.checkTTL
+	}
+	return defaultCheckTTL
+}
+
+// keepAlive marks the ttl checks passing until stop is closed
+func (c *Consul) keepAlive(stop chan struct{}) {
+	ticker := time.NewTicker(c.ttl() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.registerMu.Lock()
+			checks := make([]string, 0, len(c.checks))
+			for check := range c.checks {
+				checks = append(checks, check)
+			}
+			c.registerMu.Unlock()
+
+			for _, check := range checks {
+				if err := c.client.Agent().UpdateTTL(check, "", api.HealthPassing); err != nil {
+					log.Errorf("consul ttl check %s update error, %v", check, err)
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package consul
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/lubanproj/gorpc/plugin"
+	"github.com/lubanproj/gorpc/registry"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	fake := newFakeConsul()
+	defer fake.Close()
+
+	c := newTestConsul(t, strings.TrimPrefix(fake.URL, "http://"))
+	c.checkTTL = 300 * time.Millisecond
+	defer c.Close()
+
+	err := c.Init(
+		plugin.WithServices([]string{"helloworld.Greeter"}),
+		plugin.WithSvrAddr("127.0.0.1:8000"),
+		plugin.WithWeight(5),
+		plugin.WithTags([]string{"canary"}),
+		plugin.WithMeta(map[string]string{"version": "v1.2.0", "zone": "us-east-1a"}),
+	)
+	assert.Nil(t, err)
+
+	registration := fake.registration("helloworld.Greeter-127.0.0.1:8000")
+	if assert.NotNil(t, registration) {
+		assert.Equal(t, "helloworld.Greeter", registration.Name)
+		assert.Equal(t, "127.0.0.1", registration.Address)
+		assert.Equal(t, 8000, registration.Port)
+		assert.Equal(t, []string{"canary"}, registration.Tags)
+		assert.Equal(t, map[string]string{"version": "v1.2.0", "zone": "us-east-1a"}, registration.Meta)
+		assert.Equal(t, 5, registration.Weights.Passing)
+		assert.Equal(t, "300ms", registration.Check.TTL)
+		assert.Equal(t, "1m0s", registration.Check.DeregisterCriticalServiceAfter)
+	}
+
+	nodes, err := c.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(nodes)) {
+		assert.Equal(t, "127.0.0.1:8000", nodes[0].Address)
+		assert.Equal(t, 5, nodes[0].Weight)
+	}
+
+	// the ttl expired, the instances which are not passing are not resolved
+	fake.setStatus("service:helloworld.Greeter-127.0.0.1:8000", api.HealthCritical)
+	assert.Eventually(t, func() bool {
+		_, err := c.Resolve("helloworld.Greeter")
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+
+	// the server keeps its check passing
+	assert.Eventually(t, func() bool {
+		nodes, err := c.Resolve("helloworld.Greeter")
+		return err == nil && len(nodes) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, c.Deregister(&registry.Instance{ServiceName: "helloworld.Greeter", Address: "127.0.0.1:8000"}))
+	// the ttl check isn't kept passing any more
+	assert.Nil(t, c.stop)
+	assert.Nil(t, fake.registration("helloworld.Greeter-127.0.0.1:8000"))
+	assert.Eventually(t, func() bool {
+		_, err := c.Resolve("helloworld.Greeter")
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRegisterTCPCheck(t *testing.T) {
+	fake := newFakeConsul()
+	defer fake.Close()
+
+	c := newTestConsul(t, strings.TrimPrefix(fake.URL, "http://"))
+	defer c.Close()
+
+	err := c.Init(
+		plugin.WithServices([]string{"helloworld.Greeter"}),
+		plugin.WithSvrAddr("127.0.0.1:8000"),
+		plugin.WithHealthCheck(plugin.HealthCheckTCP),
+	)
+	assert.Nil(t, err)
+	defer c.Deregister(&registry.Instance{ServiceName: "helloworld.Greeter", Address: "127.0.0.1:8000"})
+
+	registration := fake.registration("helloworld.Greeter-127.0.0.1:8000")
+	if assert.NotNil(t, registration) {
+		assert.Equal(t, "127.0.0.1:8000", registration.Check.TCP)
+		assert.Equal(t, "", registration.Check.TTL)
+		assert.Equal(t, 1, registration.Weights.Passing)
+	}
+	// the tcp check isn't kept passing by the server
+	assert.Nil(t, c.stop)
+
+	// invalid server address
+	err = c.Init(plugin.WithSvrAddr("127.0.0.1"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,186 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/registry"
+)
+
+const (
+	// defaultWaitTime is the max duration of a blocking query
+	defaultWaitTime = 30 * time.Second
+
+	// defaultRetryInterval is the interval of the queries while consul is unreachable
+	defaultRetryInterval = time.Second
+
+	// resolveTimeout is how long a resolution waits for the first query of a service
+	resolveTimeout = 5 * time.Second
+)
+
+// watcher keeps a local cache of the instances of a service, it's kept fresh by blocking queries,
+// which return as soon as the instances change. The last known good instances are kept while consul is unreachable.
+type watcher struct {
+	serviceName string
+	ready       chan struct{} // closed once the first query returns
+
+	mu        sync.RWMutex
+	instances []*registry.Instance
+	err       error // error of the latest query
+	good      bool  // whether a query ever succeeded
+}
+
+// watch returns the watcher of the service, the first call starts it
+func (c *Consul) watch(serviceName string) *watcher {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+
+	if w, ok := c.watchers[serviceName]; ok {
+		return w
+	}
+	if c.watchers == nil {
+		c.watchers = make(map[string]*watcher)
+	}
+	if c.ctx == nil {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+
+	w := &watcher{
+		serviceName: serviceName,
+		ready:       make(chan struct{}),
+	}
+	c.watchers[serviceName] = w
+	go c.run(c.ctx, w)
+
+	return w
+}
+
+// run queries the instances of the service until ctx is canceled
+func (c *Consul) run(ctx context.Context, w *watcher) {
+	waitTime, retryInterval := c.waitTime, c.retryInterval
+	if waitTime <= 0 {
+		waitTime = defaultWaitTime
+	}
+	if retryInterval <= 0 {
+		retryInterval = defaultRetryInterval
+	}
+
+	var index uint64
+	first := true
+	for {
+		opts := &api.QueryOptions{
+			WaitIndex: index,
+			WaitTime:  waitTime,
+		}
+		instances, lastIndex, err := c.query(w.serviceName, opts.WithContext(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		w.update(instances, err)
+		if err == nil {
+			c.subscribers.Notify(w.serviceName, instances)
+		}
+		if first {
+			close(w.ready)
+			first = false
+		}
+
+		if err != nil {
+			log.Errorf("consul query of %s error, keep the last known instances, %v", w.serviceName, err)
+			select {
+			case <-time.After(retryInterval):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		// the index is reset when it goes backwards, e.g. : consul restarted
+		if lastIndex < index {
+			index = 0
+		} else {
+			index = lastIndex
+		}
+	}
+}
+
+// query lists the passing instances of the service, it blocks until they change if opts.WaitIndex is set
+func (c *Consul) query(serviceName string, opts *api.QueryOptions) ([]*registry.Instance, uint64, error) {
+	entries, meta, err := c.client.Health().Service(serviceName, "", true, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseEntries(entries), meta.LastIndex, nil
+}
+
+// parseEntries converts the health entries of a service into instances
+func parseEntries(entries []*api.ServiceEntry) []*registry.Instance {
+	instances := make([]*registry.Instance, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Service == nil {
+			continue
+		}
+		host := entry.Service.Address
+		if host == "" && entry.Node != nil {
+			// the address of the node is used if the service has none
+			host = entry.Node.Address
+		}
+		instance := &registry.Instance{
+			ServiceName: entry.Service.Service,
+			Address:     net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)),
+			Weight:      entry.Service.Weights.Passing,
+			Tags:        entry.Service.Tags,
+			Meta:        entry.Service.Meta,
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+func (w *watcher) update(instances []*registry.Instance, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.err = err
+	if err == nil {
+		w.instances, w.good = instances, true
+	}
+}
+
+// resolve returns the cached instances, it waits for the first query of the service
+func (w *watcher) resolve() ([]*registry.Instance, error) {
+	select {
+	case <-w.ready:
+	case <-time.After(resolveTimeout):
+		return nil, fmt.Errorf("consul query of %s timeout", w.serviceName)
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.good {
+		return nil, w.err
+	}
+	if len(w.instances) == 0 {
+		return nil, fmt.Errorf("no services find in path : %s", w.serviceName)
+	}
+	return w.instances, nil
+}
+
+// Close stops the watches of the services, the blocking queries in flight are canceled
+func (c *Consul) Close() {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+		c.ctx, c.cancel = nil, nil
+	}
+	c.watchers = nil
+}
@@ -0,0 +1,310 @@
+package consul
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/lubanproj/gorpc/plugin"
+	"github.com/lubanproj/gorpc/registry"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConsul serves the agent service API and the health API with blocking queries
+type fakeConsul struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	changed  *sync.Cond
+	index    uint64
+	services map[string]*fakeService // service id -> service
+	down     bool
+	queries  int64
+	canceled int64 // blocking queries canceled by the client
+}
+
+type fakeService struct {
+	registration *api.AgentServiceRegistration
+	status       string
+}
+
+func newFakeConsul() *fakeConsul {
+	f := &fakeConsul{index: 1, services: make(map[string]*fakeService)}
+	f.changed = sync.NewCond(&f.mu)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/health/service/", f.health)
+	mux.HandleFunc("/v1/agent/service/register", f.register)
+	mux.HandleFunc("/v1/agent/service/deregister/", f.deregister)
+	mux.HandleFunc("/v1/agent/check/update/", f.update)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+func (f *fakeConsul) health(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&f.queries, 1)
+	name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	passingOnly := r.URL.Query().Get(api.HealthPassing) == "1"
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+
+	ctx := r.Context()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			f.changed.Broadcast()
+			f.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	f.mu.Lock()
+	// blocks until the index changes, the wait time elapses or the client cancels the query
+	deadline := time.Now().Add(wait)
+	for index > 0 && index == f.index && !f.down && time.Now().Before(deadline) && ctx.Err() == nil {
+		timer := time.AfterFunc(time.Until(deadline), f.changed.Broadcast)
+		f.changed.Wait()
+		timer.Stop()
+	}
+	if ctx.Err() != nil {
+		f.mu.Unlock()
+		atomic.AddInt64(&f.canceled, 1)
+		return
+	}
+	if f.down {
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var ids []string
+	for id := range f.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := []*api.ServiceEntry{}
+	for _, id := range ids {
+		s := f.services[id]
+		if s.registration.Name != name || (passingOnly && s.status != api.HealthPassing) {
+			continue
+		}
+		weights := api.AgentWeights{Passing: 1, Warning: 1}
+		if s.registration.Weights != nil {
+			weights = *s.registration.Weights
+		}
+		entries = append(entries, &api.ServiceEntry{
+			Node: &api.Node{Address: "127.0.0.1"},
+			Service: &api.AgentService{
+				ID:      s.registration.ID,
+				Service: s.registration.Name,
+				Tags:    s.registration.Tags,
+				Meta:    s.registration.Meta,
+				Address: s.registration.Address,
+				Port:    s.registration.Port,
+				Weights: weights,
+			},
+		})
+	}
+	lastIndex := f.index
+	f.mu.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(lastIndex, 10))
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (f *fakeConsul) register(w http.ResponseWriter, r *http.Request) {
+	registration := &api.AgentServiceRegistration{}
+	if err := json.NewDecoder(r.Body).Decode(registration); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	status := api.HealthCritical
+	if registration.Check != nil && registration.Check.Status != "" {
+		status = registration.Check.Status
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services[registration.ID] = &fakeService{registration: registration, status: status}
+	f.bump()
+}
+
+func (f *fakeConsul) deregister(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+	f.bump()
+}
+
+func (f *fakeConsul) update(w http.ResponseWriter, r *http.Request) {
+	var update struct{ Status string }
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.setStatus(strings.TrimPrefix(r.URL.Path, "/v1/agent/check/update/"), update.Status)
+}
+
+// setStatus sets the status of a check, e.g. : its ttl expired
+func (f *fakeConsul) setStatus(checkID, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.services {
+		if s.registration.Check != nil && s.registration.Check.CheckID == checkID && s.status != status {
+			s.status = status
+			f.bump()
+		}
+	}
+}
+
+func (f *fakeConsul) registration(id string) *api.AgentServiceRegistration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.services[id]; ok {
+		return s.registration
+	}
+	return nil
+}
+
+// bump notifies the blocking queries of a change, f.mu is held
+func (f *fakeConsul) bump() {
+	f.index++
+	f.changed.Broadcast()
+}
+
+// set replaces the services by passing instances of helloworld.Greeter
+func (f *fakeConsul) set(down bool, addrs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.services = make(map[string]*fakeService)
+	for _, addr := range addrs {
+		host, port, _ := net.SplitHostPort(addr)
+		p, _ := strconv.Atoi(port)
+		f.services[addr] = &fakeService{
+			registration: &api.AgentServiceRegistration{ID: addr, Name: "helloworld.Greeter", Address: host, Port: p},
+			status:       api.HealthPassing,
+		}
+	}
+	f.down = down
+	f.bump()
+}
+
+func newTestConsul(t *testing.T, addr string) *Consul {
+	c := &Consul{
+		opts:          &plugin.Options{SelectorSvrAddr: addr},
+		waitTime:      time.Second,
+		retryInterval: 10 * time.Millisecond,
+	}
+	assert.Nil(t, c.InitConfig())
+	return c
+}
+
+func TestResolveWatch(t *testing.T) {
+	fake := newFakeConsul()
+	defer fake.Close()
+	fake.set(false, "10.0.0.1:8000", "10.0.0.2:8000")
+
+	c := newTestConsul(t, strings.TrimPrefix(fake.URL, "http://"))
+	defer c.Close()
+
+	nodes, err := c.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(nodes))
+
+	// the calls are served from the cache
+	queries := atomic.LoadInt64(&fake.queries)
+	for i := 0; i < 100; i++ {
+		_, err = c.Resolve("helloworld.Greeter")
+		assert.Nil(t, err)
+	}
+	assert.True(t, atomic.LoadInt64(&fake.queries) <= queries+1)
+
+	// the changes are pushed by the blocking query
+	fake.set(false, "10.0.0.3:8000")
+	assert.Eventually(t, func() bool {
+		nodes, err := c.Resolve("helloworld.Greeter")
+		return err == nil && len(nodes) == 1 && nodes[0].Address == "10.0.0.3:8000"
+	}, time.Second, 10*time.Millisecond)
+
+	// the last known nodes are kept while consul is unreachable
+	fake.set(true)
+	time.Sleep(50 * time.Millisecond)
+	nodes, err = c.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.3:8000", nodes[0].Address)
+
+	// and updated once it's back
+	fake.set(false, "10.0.0.4:8000")
+	assert.Eventually(t, func() bool {
+		nodes, err := c.Resolve("helloworld.Greeter")
+		return err == nil && len(nodes) == 1 && nodes[0].Address == "10.0.0.4:8000"
+	}, time.Second, 10*time.Millisecond)
+
+	// no node registered
+	_, err = c.Resolve("unknown.Greeter")
+	assert.NotNil(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	fake := newFakeConsul()
+	defer fake.Close()
+	fake.set(false, "10.0.0.1:8000")
+
+	c := newTestConsul(t, strings.TrimPrefix(fake.URL, "http://"))
+	defer c.Close()
+
+	w, err := c.Watch("helloworld.Greeter")
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	instances, err := w.Next()
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(instances)) {
+		assert.Equal(t, "10.0.0.1:8000", instances[0].Address)
+	}
+
+	// the changes are pushed by the blocking query
+	fake.set(false, "10.0.0.1:8000", "10.0.0.2:8000")
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(instances))
+
+	w.Stop()
+	_, err = w.Next()
+	assert.Equal(t, registry.ErrWatcherStopped, err)
+}
+
+func TestCloseCancelsQuery(t *testing.T) {
+	fake := newFakeConsul()
+	defer fake.Close()
+	fake.set(false, "10.0.0.1:8000")
+
+	c := newTestConsul(t, strings.TrimPrefix(fake.URL, "http://"))
+	c.waitTime = time.Minute
+
+	_, err := c.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&fake.queries) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// the blocking query in flight doesn't wait for its wait time
+	c.Close()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&fake.canceled) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&fake.queries))
+}
@@ -7,7 +7,8 @@ type Plugin interface {
 
 }
 
-// ResolverPlugin 定义了所有服务发现插件的标准
+// ResolverPlugin 定义了所有服务发现插件的标准, the plugins which are also a registry.Registry
+// register the server in Init, and deregister it when the server closes
 type ResolverPlugin interface {
 	Init(...Option) error
 }
@@ -36,8 +37,17 @@ type Options struct {
 	Services []string   // service arrays
 	SelectorSvrAddr string  // server discovery address ，e.g. consul server address
 	TracingSvrAddr string   // tracing server address，e.g. jaeger server address
+	Weight int  // weight of the server registered, the default weight is used if <= 0
+	Tags []string // tags of the server registered
+	Meta map[string]string // meta of the server registered, e.g. : version、zone
+	HealthCheck string // health check of the server registered, HealthCheckTTL or HealthCheckTCP, HealthCheckTTL if empty
 }
 
+const (
+	HealthCheckTTL = "ttl" // the server keeps its check passing, it fails once the server stops
+	HealthCheckTCP = "tcp" // the server discovery dials the server
+)
+
 // Option provides operations on Options
 type Option func(*Options)
 
@@ -69,6 +79,30 @@ func WithTracingSvrAddr(addr string) Option {
 	}
 }
 
+// WithWeight allows you to set Weight of Options
+func WithWeight(weight int) Option {
+	return func(o *Options) {
+		o.Weight = weight
+	}
+}
 
+// WithTags allows you to set Tags of Options
+func WithTags(tags []string) Option {
+	return func(o *Options) {
+		o.Tags = tags
+	}
+}
 
+// WithMeta allows you to set Meta of Options
+func WithMeta(meta map[string]string) Option {
+	return func(o *Options) {
+		o.Meta = meta
+	}
+}
 
+// WithHealthCheck allows you to set HealthCheck of Options
+func WithHealthCheck(healthCheck string) Option {
+	return func(o *Options) {
+		o.HealthCheck = healthCheck
+	}
+}
@@ -45,9 +45,16 @@ func (p *PoolConn) MarkUnusable() {
 	p.mu.Unlock()
 }
 
+// 连接是否不可用
+func (p *PoolConn) isUnusable() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.unusable
+}
+
 func (p *PoolConn) Read(b []byte) (int, error) {
 	//如果连接是不可用状态就返回连接关闭
-	if p.unusable {
+	if p.isUnusable() {
 		return 0, ErrConnClosed
 	}
 	n, err := p.Conn.Read(b)
@@ -59,7 +66,7 @@ func (p *PoolConn) Read(b []byte) (int, error) {
 }
 
 func (p *PoolConn) Write(b []byte) (int, error) {
-	if p.unusable {
+	if p.isUnusable() {
 		return 0, ErrConnClosed
 	}
 	n, err := p.Conn.Write(b)
@@ -0,0 +1,304 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lubanproj/gorpc/log"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultPollInterval is the interval at which a FileRegistry checks its file for changes
+const DefaultPollInterval = time.Second
+
+// fileContent is the content of the file of a FileRegistry, e.g. :
+//
+//	services:
+//	  helloworld.Greeter:
+//	    - address: 127.0.0.1:8000
+//	      weight: 10
+//	      meta:
+//	        zone: us-east-1a
+type fileContent struct {
+	Services map[string][]*Instance `json:"services" yaml:"services"`
+}
+
+// FileOption provides operations on a FileRegistry
+type FileOption func(*FileRegistry)
+
+// WithPollInterval returns a FileOption which sets the interval at which the file is checked for changes
+func WithPollInterval(interval time.Duration) FileOption {
+	return func(f *FileRegistry) {
+		f.pollInterval = interval
+	}
+}
+
+// FileRegistry keeps the instances in a JSON or YAML file, which is picked by the extension of the file :
+// .json, or .yaml and .yml. The file is polled, the changes made to it, e.g. : by a deployment tool,
+// are picked up and notified to the watchers. The last good instances are kept if the file is invalid.
+type FileRegistry struct {
+	path         string
+	pollInterval time.Duration
+
+	mu       sync.RWMutex
+	raw      []byte                 // content of the file last loaded
+	services map[string][]*Instance // service name -> instances sorted by address
+	watchers Watchers
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewFileRegistry creates a FileRegistry of the file, a file which doesn't exist has no instances,
+// it's created by the first Register
+func NewFileRegistry(path string, opts ...FileOption) (*FileRegistry, error) {
+	if _, err := decoder(path); err != nil {
+		return nil, err
+	}
+
+	f := &FileRegistry{
+		path:         path,
+		pollInterval: DefaultPollInterval,
+		services:     make(map[string][]*Instance),
+		stop:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	go f.poll()
+
+	return f, nil
+}
+
+// Register adds an instance to the file, it replaces the instance of the same address
+func (f *FileRegistry) Register(instance *Instance) error {
+	if instance == nil || instance.ServiceName == "" || instance.Address == "" {
+		return errors.New("instance has no service name or address")
+	}
+
+	return f.update(func(services map[string][]*Instance) {
+		instances := removeInstance(services[instance.ServiceName], instance.Address)
+		i := *instance
+		i.ServiceName = ""
+		services[instance.ServiceName] = append(instances, &i)
+	})
+}
+
+// Deregister removes the instance of the address of instance from the file
+func (f *FileRegistry) Deregister(instance *Instance) error {
+	if instance == nil {
+		return nil
+	}
+
+	return f.update(func(services map[string][]*Instance) {
+		instances := removeInstance(services[instance.ServiceName], instance.Address)
+		if len(instances) == 0 {
+			delete(services, instance.ServiceName)
+			return
+		}
+		services[instance.ServiceName] = instances
+	})
+}
+
+// Resolve returns the instances of the service in the file last loaded
+func (f *FileRegistry) Resolve(serviceName string) ([]*Instance, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	instances := f.services[serviceName]
+	if len(instances) == 0 {
+		return nil, ErrNoInstances
+	}
+	return instances, nil
+}
+
+// Watch returns a Watcher of the instances of the service
+func (f *FileRegistry) Watch(serviceName string) (Watcher, error) {
+	return f.watchers.Watch(serviceName), nil
+}
+
+// Close stops polling the file
+func (f *FileRegistry) Close() {
+	f.once.Do(func() {
+		close(f.stop)
+	})
+}
+
+func (f *FileRegistry) poll() {
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.load(); err != nil {
+				log.Errorf("registry file %s load error, keep the last good instances, %v", f.path, err)
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// load reads the file and notifies the watchers of the services changed
+func (f *FileRegistry) load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, err := ioutil.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if f.raw != nil && bytes.Equal(raw, f.raw) {
+		return nil
+	}
+
+	services, err := f.parse(raw)
+	if err != nil {
+		return err
+	}
+	f.raw = raw
+	f.apply(services)
+	return nil
+}
+
+// update applies a change to the content of the file, and writes it
+func (f *FileRegistry) update(change func(services map[string][]*Instance)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// the file is read again, so that the changes made by the other servers are kept
+	raw, err := ioutil.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	services, err := f.parse(raw)
+	if err != nil {
+		return err
+	}
+
+	change(services)
+
+	if raw, err = f.encode(services); err != nil {
+		return err
+	}
+	if err = writeFile(f.path, raw); err != nil {
+		return err
+	}
+	f.raw = raw
+	f.apply(services)
+	return nil
+}
+
+// apply sets the instances of the services, and notifies the watchers of the ones changed, f.mu is held
+func (f *FileRegistry) apply(services map[string][]*Instance) {
+	for serviceName, instances := range services {
+		for _, instance := range instances {
+			instance.ServiceName = serviceName
+		}
+		sort.Slice(instances, func(i, j int) bool { return instances[i].Address < instances[j].Address })
+		f.watchers.Notify(serviceName, instances)
+	}
+	for serviceName := range f.services {
+		if _, ok := services[serviceName]; !ok {
+			f.watchers.Notify(serviceName, []*Instance{})
+		}
+	}
+	f.services = services
+}
+
+func (f *FileRegistry) parse(raw []byte) (map[string][]*Instance, error) {
+	content := &fileContent{}
+	if len(bytes.TrimSpace(raw)) > 0 {
+		unmarshal, _ := decoder(f.path)
+		if err := unmarshal(raw, content); err != nil {
+			return nil, err
+		}
+	}
+
+	services := make(map[string][]*Instance, len(content.Services))
+	for serviceName, instances := range content.Services {
+		for _, instance := range instances {
+			if instance == nil || instance.Address == "" {
+				return nil, errors.New("instance of " + serviceName + " has no address")
+			}
+		}
+		if len(instances) > 0 {
+			services[serviceName] = instances
+		}
+	}
+	return services, nil
+}
+
+func (f *FileRegistry) encode(services map[string][]*Instance) ([]byte, error) {
+	content := &fileContent{Services: make(map[string][]*Instance, len(services))}
+	for serviceName, instances := range services {
+		list := make([]*Instance, 0, len(instances))
+		for _, instance := range instances {
+			i := *instance
+			i.ServiceName = ""
+			list = append(list, &i)
+		}
+		content.Services[serviceName] = list
+	}
+
+	if isYAML(f.path) {
+		return yaml.Marshal(content)
+	}
+	return json.MarshalIndent(content, "", "  ")
+}
+
+func decoder(path string) (func([]byte, interface{}) error, error) {
+	switch {
+	case isYAML(path):
+		return yaml.Unmarshal, nil
+	case strings.ToLower(filepath.Ext(path)) == ".json":
+		return json.Unmarshal, nil
+	}
+	return nil, errors.New("registry file " + path + " is neither .json nor .yaml")
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func removeInstance(instances []*Instance, addr string) []*Instance {
+	var list []*Instance
+	for _, instance := range instances {
+		if instance.Address != addr {
+			list = append(list, instance)
+		}
+	}
+	return list
+}
+
+// writeFile replaces the file by a temporary file, so that the file is never read half written
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "registry")
+	assert.Nil(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestFileRegistryYAML(t *testing.T) {
+	dir, clean := tempDir(t)
+	defer clean()
+
+	path := filepath.Join(dir, "services.yaml")
+	content := `
+services:
+  helloworld.Greeter:
+    - address: 10.0.0.2:8000
+    - address: 10.0.0.1:8000
+      weight: 10
+      tags: [canary]
+      meta:
+        zone: us-east-1a
+`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	r, err := NewFileRegistry(path, WithPollInterval(10*time.Millisecond))
+	assert.Nil(t, err)
+	defer r.Close()
+
+	instances, err := r.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, []*Instance{
+		{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000", Weight: 10, Tags: []string{"canary"}, Meta: map[string]string{"zone": "us-east-1a"}},
+		{ServiceName: "helloworld.Greeter", Address: "10.0.0.2:8000"},
+	}, instances)
+
+	w, err := r.Watch("helloworld.Greeter")
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	// the changes made to the file are picked up
+	content = `
+services:
+  helloworld.Greeter:
+    - address: 10.0.0.3:8000
+`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(instances)) {
+		assert.Equal(t, "10.0.0.3:8000", instances[0].Address)
+	}
+
+	// the last good instances are kept if the file is invalid
+	assert.Nil(t, ioutil.WriteFile(path, []byte("services: [invalid"), 0644))
+	time.Sleep(50 * time.Millisecond)
+	instances, err = r.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.3:8000", instances[0].Address)
+
+	// the services removed from the file are notified
+	assert.Nil(t, ioutil.WriteFile(path, []byte("services: {}"), 0644))
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(instances))
+	_, err = r.Resolve("helloworld.Greeter")
+	assert.Equal(t, ErrNoInstances, err)
+}
+
+func TestFileRegistryRegister(t *testing.T) {
+	dir, clean := tempDir(t)
+	defer clean()
+
+	// the file is created by the first Register
+	path := filepath.Join(dir, "services.json")
+	r, err := NewFileRegistry(path)
+	assert.Nil(t, err)
+	defer r.Close()
+
+	_, err = r.Resolve("helloworld.Greeter")
+	assert.Equal(t, ErrNoInstances, err)
+
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000", Weight: 10}))
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.2:8000"}))
+
+	// another server sharing the file
+	other, err := NewFileRegistry(path)
+	assert.Nil(t, err)
+	defer other.Close()
+	assert.Nil(t, other.Register(&Instance{ServiceName: "helloworld.Greeter2", Address: "10.0.0.3:8000"}))
+
+	raw, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(raw), `"address": "10.0.0.1:8000"`))
+	assert.True(t, strings.Contains(string(raw), `"weight": 10`))
+	assert.False(t, strings.Contains(string(raw), "service_name"))
+
+	instances, err := other.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(instances))
+
+	// the changes of the other server are kept
+	assert.Nil(t, r.Deregister(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000"}))
+	instances, err = r.Resolve("helloworld.Greeter2")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.3:8000", instances[0].Address)
+	instances, err = r.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(instances))
+}
+
+func TestNewFileRegistry(t *testing.T) {
+	dir, clean := tempDir(t)
+	defer clean()
+
+	_, err := NewFileRegistry(filepath.Join(dir, "services.txt"))
+	assert.NotNil(t, err)
+
+	path := filepath.Join(dir, "services.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"services":{"helloworld.Greeter":[{"weight":1}]}}`), 0644))
+	_, err = NewFileRegistry(path)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// MemoryName is the name of DefaultMemoryRegistry
+const MemoryName = "memory"
+
+func init() {
+	RegisterRegistry(MemoryName, DefaultMemoryRegistry)
+}
+
+// DefaultMemoryRegistry is a global MemoryRegistry, it discovers the servers of the same process, e.g. : in tests
+var DefaultMemoryRegistry = NewMemoryRegistry()
+
+// MemoryRegistry keeps the instances in memory
+type MemoryRegistry struct {
+	mu       sync.RWMutex
+	services map[string]map[string]*Instance // service name -> address -> instance
+	watchers Watchers
+}
+
+// NewMemoryRegistry creates an empty MemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		services: make(map[string]map[string]*Instance),
+	}
+}
+
+// Register registers an instance, it replaces the instance of the same address
+func (m *MemoryRegistry) Register(instance *Instance) error {
+	if instance == nil || instance.ServiceName == "" || instance.Address == "" {
+		return errors.New("instance has no service name or address")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instances, ok := m.services[instance.ServiceName]
+	if !ok {
+		instances = make(map[string]*Instance)
+		m.services[instance.ServiceName] = instances
+	}
+	i := *instance
+	instances[instance.Address] = &i
+
+	m.watchers.Notify(instance.ServiceName, m.list(instance.ServiceName))
+	return nil
+}
+
+// Deregister removes the instance of the address of instance
+func (m *MemoryRegistry) Deregister(instance *Instance) error {
+	if instance == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instances, ok := m.services[instance.ServiceName]
+	if !ok {
+		return nil
+	}
+	delete(instances, instance.Address)
+	if len(instances) == 0 {
+		delete(m.services, instance.ServiceName)
+	}
+
+	m.watchers.Notify(instance.ServiceName, m.list(instance.ServiceName))
+	return nil
+}
+
+// Resolve returns the instances of the service sorted by address
+func (m *MemoryRegistry) Resolve(serviceName string) ([]*Instance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	instances := m.list(serviceName)
+	if len(instances) == 0 {
+		return nil, ErrNoInstances
+	}
+	return instances, nil
+}
+
+// Watch returns a Watcher of the instances of the service
+func (m *MemoryRegistry) Watch(serviceName string) (Watcher, error) {
+	return m.watchers.Watch(serviceName), nil
+}
+
+// list returns copies of the instances of the service sorted by address, m.mu is held
+func (m *MemoryRegistry) list(serviceName string) []*Instance {
+	instances := m.services[serviceName]
+	list := make([]*Instance, 0, len(instances))
+	for _, instance := range instances {
+		i := *instance
+		list = append(list, &i)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRegistry(t *testing.T) {
+	r := NewMemoryRegistry()
+
+	_, err := r.Resolve("helloworld.Greeter")
+	assert.Equal(t, ErrNoInstances, err)
+	assert.NotNil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter"}))
+
+	w, err := r.Watch("helloworld.Greeter")
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.2:8000"}))
+	instances, err := w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(instances))
+
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000", Weight: 10}))
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(instances))
+
+	// the instances are sorted by address
+	instances, err = r.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8000", instances[0].Address)
+	assert.Equal(t, 10, instances[0].Weight)
+	assert.Equal(t, "10.0.0.2:8000", instances[1].Address)
+
+	// the instance of the same address is replaced
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000", Weight: 20}))
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 20, instances[0].Weight)
+
+	assert.Nil(t, r.Deregister(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000"}))
+	assert.Nil(t, r.Deregister(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.2:8000"}))
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(instances))
+
+	_, err = r.Resolve("helloworld.Greeter")
+	assert.Equal(t, ErrNoInstances, err)
+}
@@ -0,0 +1,219 @@
+// Package registry defines the standard of the service registries, where the servers register
+// the instances of their services and the clients resolve and watch them, e.g. : consul, a file.
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/lubanproj/gorpc/plugin"
+	"github.com/lubanproj/gorpc/selector"
+)
+
+// Instance is an instance of a service
+type Instance struct {
+	ServiceName string            `json:"service_name,omitempty" yaml:"service_name,omitempty"`
+	Address     string            `json:"address" yaml:"address"`                   // e.g. : 127.0.0.1:8000
+	Weight      int               `json:"weight,omitempty" yaml:"weight,omitempty"` // the default weight is used if <= 0
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty" yaml:"meta,omitempty"` // e.g. : version、zone
+}
+
+// Registry defines the standard of the service registries
+type Registry interface {
+	// Register registers an instance of a service
+	Register(instance *Instance) error
+	// Deregister removes an instance of a service
+	Deregister(instance *Instance) error
+	// Resolve returns the instances of a service
+	Resolve(serviceName string) ([]*Instance, error)
+	// Watch returns a Watcher of the instances of a service
+	Watch(serviceName string) (Watcher, error)
+}
+
+// Watcher watches the instances of a service
+type Watcher interface {
+	// Next blocks until the instances of the service change and returns them,
+	// only the latest instances are returned if they change several times in between
+	Next() ([]*Instance, error)
+	// Stop stops the watcher, Next returns ErrWatcherStopped then
+	Stop()
+}
+
+var (
+	// ErrWatcherStopped is returned by Next once the Watcher is stopped
+	ErrWatcherStopped = errors.New("registry watcher stopped")
+
+	// ErrNoInstances is returned by Resolve if the service has no instances
+	ErrNoInstances = errors.New("registry has no instances of the service")
+)
+
+var (
+	registryMu  sync.RWMutex
+	registryMap = make(map[string]Registry)
+)
+
+// RegisterRegistry registers a Registry, so that it plugs into the framework by its name :
+// a server with WithPlugin(name) registers in it, and the clients select the nodes of a service
+// in it with WithSelectorName(name), or with a target of the scheme name, e.g. : name://service-name
+func RegisterRegistry(name string, r Registry) {
+	registryMu.Lock()
+	registryMap[name] = r
+	registryMu.Unlock()
+
+	plugin.Register(name, r)
+	selector.RegisterSelector(name, NewSelector(r, selector.RoundRobin))
+	selector.RegisterScheme(name, func(target selector.Target) (selector.Selector, error) {
+		return &registrySelector{
+			registry:    r,
+			serviceName: target.Endpoint,
+			balancer:    target.Balancer(),
+		}, nil
+	})
+}
+
+// GetRegistry returns the Registry registered by the name, nil if there is none
+func GetRegistry(name string) Registry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return registryMap[name]
+}
+
+// Nodes converts instances into the nodes picked by the balancers
+func Nodes(instances []*Instance) []*selector.Node {
+	nodes := make([]*selector.Node, 0, len(instances))
+	for _, instance := range instances {
+		nodes = append(nodes, &selector.Node{
+			Key:     instance.ServiceName + "/" + instance.Address,
+			Address: instance.Address,
+			Weight:  instance.Weight,
+		})
+	}
+	return nodes
+}
+
+// registrySelector selects the nodes of a service in a registry
+type registrySelector struct {
+	registry    Registry
+	serviceName string // service resolved whatever the service called, e.g. : the endpoint of a target
+	balancer    string
+}
+
+// NewSelector creates a Selector which resolves the instances of the services in a registry,
+// and balances over them by the balancer
+func NewSelector(r Registry, balancer string) selector.Selector {
+	return &registrySelector{
+		registry: r,
+		balancer: balancer,
+	}
+}
+
+func (s *registrySelector) Select(serviceName string, opts ...selector.Option) (string, error) {
+	name := serviceName
+	if s.serviceName != "" {
+		name = s.serviceName
+	}
+
+	instances, err := s.registry.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+
+	node, err := selector.Pick(serviceName, Nodes(instances), selector.GetBalancer(s.balancer), opts...)
+	if err != nil {
+		return "", err
+	}
+	return node.Address, nil
+}
+
+// Watchers keeps the watchers of the services of a registry, the registries notify them of the changes.
+// The zero value is ready to use.
+type Watchers struct {
+	mu       sync.Mutex
+	watchers map[string]map[*watcher]struct{} // service name -> watchers
+	last     map[string][]*Instance           // service name -> instances last notified
+}
+
+// Watch returns a new Watcher of the service
+func (ws *Watchers) Watch(serviceName string) Watcher {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.watchers == nil {
+		ws.watchers = make(map[string]map[*watcher]struct{})
+	}
+	if ws.watchers[serviceName] == nil {
+		ws.watchers[serviceName] = make(map[*watcher]struct{})
+	}
+
+	w := &watcher{
+		watchers:    ws,
+		serviceName: serviceName,
+		updates:     make(chan []*Instance, 1),
+		stop:        make(chan struct{}),
+	}
+	ws.watchers[serviceName][w] = struct{}{}
+	return w
+}
+
+// Notify sends the instances of the service to its watchers, unless they are the ones last notified
+func (ws *Watchers) Notify(serviceName string, instances []*Instance) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.last == nil {
+		ws.last = make(map[string][]*Instance)
+	}
+	if last, ok := ws.last[serviceName]; ok && reflect.DeepEqual(last, instances) {
+		return
+	}
+	ws.last[serviceName] = instances
+
+	for w := range ws.watchers[serviceName] {
+		// only the latest instances are kept for a watcher which hasn't received the former ones
+		select {
+		case <-w.updates:
+		default:
+		}
+		w.updates <- instances
+	}
+}
+
+func (ws *Watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	delete(ws.watchers[w.serviceName], w)
+}
+
+type watcher struct {
+	watchers    *Watchers
+	serviceName string
+	updates     chan []*Instance
+	stop        chan struct{}
+	once        sync.Once
+}
+
+func (w *watcher) Next() ([]*Instance, error) {
+	select {
+	case <-w.stop:
+		return nil, ErrWatcherStopped
+	default:
+	}
+
+	select {
+	case instances := <-w.updates:
+		return instances, nil
+	case <-w.stop:
+		return nil, ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+		w.watchers.remove(w)
+	})
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/selector"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchers(t *testing.T) {
+	var ws Watchers
+	w := ws.Watch("helloworld.Greeter")
+
+	// only the latest instances are returned
+	ws.Notify("helloworld.Greeter", []*Instance{{Address: "10.0.0.1:8000"}})
+	ws.Notify("helloworld.Greeter", []*Instance{{Address: "10.0.0.2:8000"}})
+	instances, err := w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, []*Instance{{Address: "10.0.0.2:8000"}}, instances)
+
+	// the same instances aren't notified again
+	ws.Notify("helloworld.Greeter", []*Instance{{Address: "10.0.0.2:8000"}})
+	next := make(chan []*Instance, 1)
+	go func() {
+		instances, _ := w.Next()
+		next <- instances
+	}()
+	select {
+	case <-next:
+		t.Fatal("unchanged instances notified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Next returns once the watcher is stopped
+	w.Stop()
+	assert.Nil(t, <-next)
+	_, err = w.Next()
+	assert.Equal(t, ErrWatcherStopped, err)
+	assert.Equal(t, 0, len(ws.watchers["helloworld.Greeter"]))
+}
+
+func TestSelector(t *testing.T) {
+	r := NewMemoryRegistry()
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000"}))
+
+	addr, err := NewSelector(r, selector.RoundRobin).Select("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8000", addr)
+
+	_, err = NewSelector(r, selector.RoundRobin).Select("unknown.Greeter")
+	assert.Equal(t, ErrNoInstances, err)
+}
+
+func TestRegisterRegistry(t *testing.T) {
+	r := NewMemoryRegistry()
+	RegisterRegistry("test-registry", r)
+	assert.Equal(t, r, GetRegistry("test-registry"))
+	assert.Nil(t, GetRegistry("unknown"))
+	assert.Equal(t, DefaultMemoryRegistry, GetRegistry(MemoryName))
+
+	assert.Nil(t, r.Register(&Instance{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000"}))
+
+	// the registry is a selector
+	addr, err := selector.GetSelector("test-registry").Select("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8000", addr)
+
+	// and a target scheme, the endpoint is the service resolved
+	sel, ok, err := selector.GetTargetSelector("test-registry://helloworld.Greeter?balancer=random")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	addr, err = sel.Select("/helloworld.Greeter/SayHello")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8000", addr)
+}
+
+func TestNodes(t *testing.T) {
+	nodes := Nodes([]*Instance{{ServiceName: "helloworld.Greeter", Address: "10.0.0.1:8000", Weight: 10}})
+	assert.Equal(t, []*selector.Node{{Key: "helloworld.Greeter/10.0.0.1:8000", Address: "10.0.0.1:8000", Weight: 10}}, nodes)
+}
@@ -2,6 +2,7 @@ package selector
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -10,6 +11,18 @@ type Balancer interface {
 	Balance(string, []*Node) *Node
 }
 
+// Filter reports whether a node can be picked by a call, e.g. : its circuit breaker is closed
+// and the call hasn't tried it yet
+type Filter func(node *Node) bool
+
+// FilterBalancer is a Balancer whose state is kept by the full node set of a service, e.g. : a position.
+// Pick gives it all the resolved nodes and a Filter of the nodes the call can pick, so that the nodes
+// filtered out by a call, e.g. : by a retry, don't reset its state.
+type FilterBalancer interface {
+	Balancer
+	BalanceFilter(serviceName string, nodes []*Node, filter Filter) *Node
+}
+
 var balancerMap = make(map[string]Balancer, 0)
 
 const (
@@ -17,6 +30,7 @@ const (
 	RoundRobin         = "roundRobin"         //轮询算法
 	WeightedRoundRobin = "weightedRoundRobin" //加权轮询算法
 	ConsistentHash     = "consistentHash"     //一致性哈希
+	P2C                = "p2c"                // power of two choices, picks the less loaded of two random nodes
 
 	Custom = "custom"
 )
@@ -25,6 +39,8 @@ func init() {
 	RegisterBalancer(Random, DefaultBalancer)
 	RegisterBalancer(RoundRobin, RRBalancer)
 	RegisterBalancer(WeightedRoundRobin, WRRBalancer)
+	RegisterBalancer(ConsistentHash, CHBalancer)
+	RegisterBalancer(P2C, P2CBalancer)
 }
 
 // RandomBalancer is adopted as the default load balancer
@@ -36,6 +52,12 @@ var RRBalancer = newRoundRobinBalancer()
 // A unique WeightedRoundRobinBalancer instance is used globally
 var WRRBalancer = newWeightedRoundRobinBalancer()
 
+// A unique ConsistentHashBalancer instance is used globally
+var CHBalancer = newConsistentHashBalancer()
+
+// A unique P2CBalancer instance is used globally
+var P2CBalancer = newP2CBalancer()
+
 // RegisterBalancer supports business custom registered Balancer
 func RegisterBalancer(name string, balancer Balancer) {
 	if balancerMap == nil {
@@ -53,17 +75,48 @@ func GetBalancer(name string) Balancer {
 }
 
 func newRandomBalancer() *randomBalancer {
-	return &randomBalancer{}
+	return &randomBalancer{
+		rand: newLockedRand(),
+	}
 }
 
 type randomBalancer struct {
+	rand *lockedRand
 }
 
 func (r *randomBalancer) Balance(serviceName string, nodes []*Node) *Node {
 	if len(nodes) == 0 {
 		return nil
 	}
-	rand.Seed(time.Now().Unix())
-	num := rand.Intn(len(nodes))
+	num := r.rand.Intn(len(nodes))
 	return nodes[num]
 }
+
+// lockedRand is the random source of a balancer, it leaves the global source of the process alone
+// and is safe for concurrent use
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand() *lockedRand {
+	return &lockedRand{
+		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Intn returns a random number in [0, n)
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.r.Intn(n)
+}
+
+// Float64 returns a random number in [0.0, 1.0)
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.r.Float64()
+}
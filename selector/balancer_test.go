@@ -0,0 +1,98 @@
+package selector
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// balanceParallel picks nodes from goroutines and returns the picks of each node
+func balanceParallel(b Balancer, serviceName string, nodes []*Node, goroutines, picks int) map[string]int {
+	var mu sync.Mutex
+	count := make(map[string]int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make(map[string]int)
+			for j := 0; j < picks; j++ {
+				local[b.Balance(serviceName, nodes).Address]++
+			}
+			mu.Lock()
+			for addr, n := range local {
+				count[addr] += n
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return count
+}
+
+func TestBalancersParallel(t *testing.T) {
+	nodes := hashNodes(4)
+	total := 16 * 1000
+
+	// round robin balancers spread the picks exactly
+	for _, b := range []Balancer{newRoundRobinBalancer(), newWeightedRoundRobinBalancer()} {
+		count := balanceParallel(b, "Greeter", nodes, 16, 1000)
+		for _, node := range nodes {
+			assert.Equal(t, total/len(nodes), count[node.Address])
+		}
+	}
+
+	// random balancers spread the picks evenly
+	for _, b := range []Balancer{newRandomBalancer(), newP2CBalancer(), newConsistentHashBalancer()} {
+		count := balanceParallel(b, "Greeter", nodes, 16, 1000)
+		for _, node := range nodes {
+			assert.InDelta(t, total/len(nodes), count[node.Address], float64(total/len(nodes))*0.1)
+		}
+	}
+}
+
+func TestRandomBalancerNotReseeded(t *testing.T) {
+	nodes := hashNodes(4)
+	b := newRandomBalancer()
+
+	// the picks in the same second differ
+	count := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		count[b.Balance("Greeter", nodes).Address]++
+	}
+	assert.Equal(t, len(nodes), len(count))
+}
+
+func benchmarkBalancer(b *testing.B, balancer Balancer) {
+	nodes := hashNodes(10)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			balancer.Balance("bench", nodes)
+		}
+	})
+}
+
+func BenchmarkRandomBalancer(b *testing.B) {
+	benchmarkBalancer(b, newRandomBalancer())
+}
+
+func BenchmarkRoundRobinBalancer(b *testing.B) {
+	benchmarkBalancer(b, newRoundRobinBalancer())
+}
+
+func BenchmarkWeightedRoundRobinBalancer(b *testing.B) {
+	benchmarkBalancer(b, newWeightedRoundRobinBalancer())
+}
+
+func BenchmarkConsistentHashBalancer(b *testing.B) {
+	balancer := newConsistentHashBalancer()
+	nodes := hashNodes(10)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			balancer.BalanceKey("bench", nodes, "user-123", nil)
+		}
+	})
+}
@@ -0,0 +1,326 @@
+package selector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+)
+
+// BreakerState is the state of the circuit breaker of a node
+type BreakerState int
+
+const (
+	StateClosed   BreakerState = iota // the node is picked
+	StateOpen                         // the node is skipped until the cool-down elapses
+	StateHalfOpen                     // a few probes are sent to the node to find out whether it recovered
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig defines when the circuit breaker of a node opens and closes
+type BreakerConfig struct {
+	Window              time.Duration    // window in which the error rate is computed
+	MinRequests         int              // min number of requests in the window before the error rate applies
+	ErrorRate           float64          // the breaker opens when the rate of failures in the window reaches it, 0 disables it
+	ConsecutiveFailures int              // the breaker opens after the consecutive failures, 0 disables it
+	CoolDown            time.Duration    // how long the breaker stays open before probing the node
+	HalfOpenProbes      int              // number of probes which must succeed to close the breaker, 1 if <= 0
+	IsFailure           func(error) bool // whether an error counts against the node, IsNodeFailure if nil
+}
+
+// DefaultBreakerConfig is the config of the circuit breakers unless SetBreakerConfig is called
+var DefaultBreakerConfig = BreakerConfig{
+	Window:              10 * time.Second,
+	MinRequests:         20,
+	ErrorRate:           0.5,
+	ConsecutiveFailures: 5,
+	CoolDown:            5 * time.Second,
+	HalfOpenProbes:      1,
+}
+
+// BreakerHook is called when the circuit breaker of a node changes its state
+type BreakerHook func(serviceName, addr string, from, to BreakerState)
+
+var (
+	breakerMu     sync.RWMutex
+	breakerConfig = DefaultBreakerConfig
+	breakerMap    = make(map[string]map[string]*breaker) // service name -> address -> breaker
+	breakerHooks  []BreakerHook
+)
+
+// SetBreakerConfig replaces the config of the circuit breakers, all the breakers are reset
+func SetBreakerConfig(cfg BreakerConfig) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	breakerConfig = cfg
+	breakerMap = make(map[string]map[string]*breaker)
+}
+
+// OnBreakerStateChange registers a hook called on the state changes of the circuit breakers,
+// e.g. : logging or exporting metrics. Hooks are called synchronously and must not block.
+func OnBreakerStateChange(hook BreakerHook) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	breakerHooks = append(breakerHooks, hook)
+}
+
+// GetBreakerState returns the state of the circuit breaker of a node
+func GetBreakerState(serviceName, addr string) BreakerState {
+	b := getBreaker(serviceName, addr, false)
+	if b == nil {
+		return StateClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// IsNodeFailure returns whether err tells that the node is unhealthy, business errors
+// and errors caused by the request or the caller don't count against the node
+func IsNodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	e, _ := codes.FromError(err)
+	if e.Type == codes.BusinuessError {
+		return false
+	}
+	switch e.Code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.ServerInternalErrorCode:
+		return true
+	}
+	return false
+}
+
+// breaker is the circuit breaker of a node
+type breaker struct {
+	mu  sync.Mutex
+	cfg BreakerConfig
+
+	state       BreakerState
+	windowStart time.Time
+	requests    int       // requests in the window
+	failures    int       // failures in the window
+	consecutive int       // consecutive failures
+	openedAt    time.Time // when the breaker opened
+	probes      int       // probes in flight
+	probeStart  time.Time // when the latest probe was sent
+	successes   int       // successful probes
+}
+
+func getBreaker(serviceName, addr string, create bool) *breaker {
+	breakerMu.RLock()
+	b, ok := breakerMap[serviceName][addr]
+	breakerMu.RUnlock()
+	if ok || !create {
+		return b
+	}
+
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	breakers, ok := breakerMap[serviceName]
+	if !ok {
+		breakers = make(map[string]*breaker)
+		breakerMap[serviceName] = breakers
+	}
+	if b, ok = breakers[addr]; !ok {
+		b = &breaker{
+			cfg:         breakerConfig,
+			windowStart: time.Now(),
+		}
+		breakers[addr] = b
+	}
+	return b
+}
+
+// pruneBreakers drops the circuit breakers of the nodes of the service which are gone,
+// so that they don't pile up as the nodes come and go
+func pruneBreakers(serviceName string, nodes []*Node) {
+	addrs := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		addrs[node.Address] = true
+	}
+
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	breakers := breakerMap[serviceName]
+	for addr := range breakers {
+		if !addrs[addr] {
+			delete(breakers, addr)
+		}
+	}
+	if len(breakers) == 0 {
+		delete(breakerMap, serviceName)
+	}
+}
+
+func notify(serviceName, addr string, from, to BreakerState) {
+	breakerMu.RLock()
+	hooks := breakerHooks
+	breakerMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(serviceName, addr, from, to)
+	}
+}
+
+func (b *breaker) halfOpenProbes() int {
+	if b.cfg.HalfOpenProbes <= 0 {
+		return 1
+	}
+	return b.cfg.HalfOpenProbes
+}
+
+// ready returns whether the node can be picked
+func (b *breaker) ready(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		return now.Sub(b.openedAt) >= b.cfg.CoolDown
+	case StateHalfOpen:
+		// a probe which never reported is given up after the cool-down
+		return b.probes < b.halfOpenProbes() || now.Sub(b.probeStart) >= b.cfg.CoolDown
+	}
+	return true
+}
+
+// acquire is called when the node is picked, an open breaker whose cool-down elapsed turns half-open
+func (b *breaker) acquire(now time.Time) (from, to BreakerState, changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from = b.state
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.cfg.CoolDown {
+		b.state = StateHalfOpen
+		b.probes = 0
+		b.successes = 0
+	}
+	if b.state == StateHalfOpen {
+		if b.probes >= b.halfOpenProbes() && now.Sub(b.probeStart) >= b.cfg.CoolDown {
+			b.probes = 0
+		}
+		b.probes++
+		b.probeStart = now
+	}
+	return from, b.state, from != b.state
+}
+
+func (b *breaker) report(err error, now time.Time) (from, to BreakerState, changed bool) {
+	isFailure := b.cfg.IsFailure
+	if isFailure == nil {
+		isFailure = IsNodeFailure
+	}
+	failure := isFailure(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from = b.state
+	switch b.state {
+	case StateClosed:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.windowStart = now
+			b.requests, b.failures = 0, 0
+		}
+		b.requests++
+		if failure {
+			b.failures++
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+		if b.tripped() {
+			b.open(now)
+		}
+	case StateHalfOpen:
+		if b.probes > 0 {
+			b.probes--
+		}
+		if failure {
+			b.open(now)
+			break
+		}
+		if b.successes++; b.successes >= b.halfOpenProbes() {
+			b.close(now)
+		}
+	}
+	// results of the calls sent before the breaker opened are ignored
+	return from, b.state, from != b.state
+}
+
+// tripped returns whether a threshold is reached
+func (b *breaker) tripped() bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutive >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+	return b.cfg.ErrorRate > 0 && b.requests >= b.cfg.MinRequests &&
+		float64(b.failures) >= b.cfg.ErrorRate*float64(b.requests)
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+}
+
+func (b *breaker) close(now time.Time) {
+	b.state = StateClosed
+	b.windowStart = now
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+	b.probes, b.successes = 0, 0
+}
+
+// available returns the nodes whose circuit breaker lets them be picked, the breakers of the nodes gone are pruned
+func available(serviceName string, nodes []*Node) []*Node {
+	now := time.Now()
+
+	breakerMu.RLock()
+	breakers := breakerMap[serviceName]
+	var left []*Node
+	found := 0
+	for _, node := range nodes {
+		b, ok := breakers[node.Address]
+		if ok {
+			found++
+			if !b.ready(now) {
+				continue
+			}
+		}
+		left = append(left, node)
+	}
+	stale := found < len(breakers)
+	breakerMu.RUnlock()
+
+	if stale {
+		pruneBreakers(serviceName, nodes)
+	}
+	return left
+}
+
+// acquireBreaker tells the circuit breaker of the node that it was picked
+func acquireBreaker(serviceName, addr string) {
+	b := getBreaker(serviceName, addr, false)
+	if b == nil {
+		return
+	}
+	if from, to, changed := b.acquire(time.Now()); changed {
+		notify(serviceName, addr, from, to)
+	}
+}
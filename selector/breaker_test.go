@@ -0,0 +1,130 @@
+package selector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	defer SetBreakerConfig(DefaultBreakerConfig)
+	SetBreakerConfig(BreakerConfig{
+		ConsecutiveFailures: 2,
+		CoolDown:            50 * time.Millisecond,
+	})
+
+	var transitions []BreakerState
+	OnBreakerStateChange(func(serviceName, addr string, from, to BreakerState) {
+		if serviceName == "breaker" {
+			transitions = append(transitions, to)
+		}
+	})
+
+	addr := "127.0.0.1:8000"
+
+	// business errors and errors of the caller don't count
+	Report("breaker", addr, time.Millisecond, codes.New(codes.Unavailable, "unavailable"))
+	Report("breaker", addr, time.Millisecond, codes.PermissionDeniedError)
+	assert.Equal(t, StateClosed, GetBreakerState("breaker", addr))
+
+	Report("breaker", addr, time.Millisecond, codes.UnavailableError)
+	assert.Equal(t, StateClosed, GetBreakerState("breaker", addr))
+	Report("breaker", addr, time.Millisecond, errors.New("connection reset"))
+	assert.Equal(t, StateOpen, GetBreakerState("breaker", addr))
+
+	// a failed probe opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	acquireBreaker("breaker", addr)
+	assert.Equal(t, StateHalfOpen, GetBreakerState("breaker", addr))
+	Report("breaker", addr, time.Millisecond, codes.DeadlineExceededError)
+	assert.Equal(t, StateOpen, GetBreakerState("breaker", addr))
+
+	// a successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	acquireBreaker("breaker", addr)
+	Report("breaker", addr, time.Millisecond, nil)
+	assert.Equal(t, StateClosed, GetBreakerState("breaker", addr))
+
+	assert.Equal(t, []BreakerState{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}, transitions)
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	defer SetBreakerConfig(DefaultBreakerConfig)
+	SetBreakerConfig(BreakerConfig{
+		Window:      time.Minute,
+		MinRequests: 4,
+		ErrorRate:   0.5,
+		CoolDown:    time.Minute,
+	})
+
+	addr := "127.0.0.1:8000"
+	Report("breaker", addr, time.Millisecond, codes.UnavailableError)
+	Report("breaker", addr, time.Millisecond, nil)
+	Report("breaker", addr, time.Millisecond, codes.UnavailableError)
+	assert.Equal(t, StateClosed, GetBreakerState("breaker", addr))
+	Report("breaker", addr, time.Millisecond, nil)
+	assert.Equal(t, StateOpen, GetBreakerState("breaker", addr))
+}
+
+func TestPickSkipsOpenNodes(t *testing.T) {
+	defer SetBreakerConfig(DefaultBreakerConfig)
+	SetBreakerConfig(BreakerConfig{
+		ConsecutiveFailures: 1,
+		CoolDown:            time.Minute,
+	})
+
+	nodes := []*Node{
+		{Address: "127.0.0.1:8000"},
+		{Address: "127.0.0.1:8001"},
+	}
+	Report("pick", nodes[0].Address, time.Millisecond, codes.UnavailableError)
+
+	for i := 0; i < 10; i++ {
+		node, err := Pick("pick", nodes, GetBalancer(RoundRobin))
+		assert.Nil(t, err)
+		assert.Equal(t, nodes[1].Address, node.Address)
+	}
+
+	// an open node is not picked even if the others are excluded
+	node, err := Pick("pick", nodes, GetBalancer(RoundRobin), WithExclude(nodes[1].Address))
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[1].Address, node.Address)
+
+	Report("pick", nodes[1].Address, time.Millisecond, codes.UnavailableError)
+	_, err = Pick("pick", nodes, GetBalancer(RoundRobin))
+	assert.Equal(t, uint32(codes.Unavailable), codes.Code(err))
+}
+
+func TestPruneBreakers(t *testing.T) {
+	defer SetBreakerConfig(DefaultBreakerConfig)
+	SetBreakerConfig(BreakerConfig{
+		ConsecutiveFailures: 1,
+		CoolDown:            time.Minute,
+	})
+
+	nodes := []*Node{
+		{Address: "127.0.0.1:8000"},
+		{Address: "127.0.0.1:8001"},
+	}
+	Report("prune", nodes[0].Address, time.Millisecond, codes.UnavailableError)
+	Report("prune", nodes[1].Address, time.Millisecond, nil)
+
+	_, err := Pick("prune", nodes, GetBalancer(RoundRobin))
+	assert.Nil(t, err)
+	assert.Len(t, breakerMap["prune"], 2)
+
+	// the breaker of the node gone is dropped
+	node, err := Pick("prune", nodes[1:], GetBalancer(RoundRobin))
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[1].Address, node.Address)
+	assert.Len(t, breakerMap["prune"], 1)
+	assert.Equal(t, StateClosed, GetBreakerState("prune", nodes[0].Address))
+
+	_, err = Pick("prune", []*Node{{Address: "127.0.0.1:8002"}}, GetBalancer(RoundRobin))
+	assert.Nil(t, err)
+	assert.NotContains(t, breakerMap, "prune")
+}
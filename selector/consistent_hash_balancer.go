@@ -0,0 +1,151 @@
+package selector
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplicas is the number of virtual nodes of a node on the hash ring
+const DefaultReplicas = 100
+
+// KeyBalancer is a Balancer which picks a node by a key of the request, e.g. : the consistent hash balancer.
+// Pick uses it when a hash key is given by WithHashKey, it's given all the resolved nodes
+// and a Filter of the nodes the call can pick, nil if it can pick them all.
+type KeyBalancer interface {
+	Balancer
+	BalanceKey(serviceName string, nodes []*Node, key string, filter Filter) *Node
+}
+
+// consistentHashBalancer routes the requests of a key to the same node as long as the node is available,
+// when a node is added or removed only the keys of its virtual nodes move. The ring of a service is built
+// from its resolved nodes, the nodes a call can't pick are skipped clockwise.
+type consistentHashBalancer struct {
+	rings    *sync.Map // service name -> *hashRing
+	replicas int
+	rand     *lockedRand
+}
+
+func newConsistentHashBalancer() *consistentHashBalancer {
+	return &consistentHashBalancer{
+		rings:    new(sync.Map),
+		replicas: DefaultReplicas,
+		rand:     newLockedRand(),
+	}
+}
+
+// hashRing is the ring of the virtual nodes of a service
+type hashRing struct {
+	mu       sync.RWMutex
+	replicas int
+	points   []point          // virtual nodes sorted by hash, then by address on hash collision
+	nodes    map[string]*Node // address -> node
+}
+
+// point is a virtual node
+type point struct {
+	hash uint32
+	node *Node
+}
+
+// Balance picks a node at random, as there is no key to hash
+func (c *consistentHashBalancer) Balance(serviceName string, nodes []*Node) *Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[c.rand.Intn(len(nodes))]
+}
+
+// BalanceKey picks the node owning the first virtual node following the hash of the key on the ring,
+// which the filter doesn't reject
+func (c *consistentHashBalancer) BalanceKey(serviceName string, nodes []*Node, key string, filter Filter) *Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	r, _ := c.rings.LoadOrStore(serviceName, &hashRing{replicas: c.replicas})
+	ring := r.(*hashRing)
+
+	ring.mu.RLock()
+	if !ring.same(nodes) {
+		ring.mu.RUnlock()
+		ring.mu.Lock()
+		if !ring.same(nodes) {
+			ring.build(nodes)
+		}
+		ring.mu.Unlock()
+		ring.mu.RLock()
+	}
+	defer ring.mu.RUnlock()
+
+	return ring.get(key, filter)
+}
+
+// same reports whether the ring is built from the nodes of the same addresses
+func (r *hashRing) same(nodes []*Node) bool {
+	if len(nodes) != len(r.nodes) {
+		return false
+	}
+	for _, node := range nodes {
+		if _, ok := r.nodes[node.Address]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// build builds the ring from the resolved nodes, it only changes when they do
+func (r *hashRing) build(nodes []*Node) {
+	r.nodes = make(map[string]*Node, len(nodes))
+	r.points = make([]point, 0, len(nodes)*r.replicas)
+	for _, node := range nodes {
+		r.nodes[node.Address] = node
+		for i := 0; i < r.replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(node.Address + "#" + strconv.Itoa(i)))
+			r.points = append(r.points, point{hash: h, node: node})
+		}
+	}
+	sortPoints(r.points)
+}
+
+// sortPoints sorts the virtual nodes by hash, a hash collision is broken by address,
+// so that the owner of a hash doesn't depend on the order of the nodes
+func sortPoints(points []point) {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].node.Address < points[j].node.Address
+	})
+}
+
+// get walks clockwise from the hash of the key to the first node the filter doesn't reject
+func (r *hashRing) get(key string, filter Filter) *Node {
+	if len(r.points) == 0 {
+		return nil
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+
+	if filter == nil {
+		return r.points[start%len(r.points)].node
+	}
+
+	// each node is checked once
+	var rejected map[string]bool
+	for i := 0; i < len(r.points) && len(rejected) < len(r.nodes); i++ {
+		node := r.points[(start+i)%len(r.points)].node
+		if rejected[node.Address] {
+			continue
+		}
+		if filter(node) {
+			return node
+		}
+		if rejected == nil {
+			rejected = make(map[string]bool)
+		}
+		rejected[node.Address] = true
+	}
+	return nil
+}
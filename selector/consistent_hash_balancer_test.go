@@ -0,0 +1,98 @@
+package selector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hashNodes(n int) []*Node {
+	var nodes []*Node
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, &Node{Address: fmt.Sprintf("10.0.0.%d:8000", i+1)})
+	}
+	return nodes
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	b := newConsistentHashBalancer()
+	nodes := hashNodes(4)
+
+	// the keys are spread over the nodes and routed to the same node
+	owners := make(map[string]string)
+	count := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		node := b.BalanceKey("Greeter", nodes, key, nil)
+		owners[key] = node.Address
+		count[node.Address]++
+		assert.Equal(t, node, b.BalanceKey("Greeter", nodes, key, nil))
+	}
+	assert.Equal(t, 4, len(count))
+	for _, n := range count {
+		assert.True(t, n > 100)
+	}
+
+	// only the keys of the node removed move
+	for key, owner := range owners {
+		node := b.BalanceKey("Greeter", nodes[1:], key, nil)
+		if owner != nodes[0].Address {
+			assert.Equal(t, owner, node.Address)
+		} else {
+			assert.NotEqual(t, owner, node.Address)
+		}
+	}
+
+	// only keys moving to the node added move
+	nodes = hashNodes(5)
+	moved := 0
+	for key, owner := range owners {
+		node := b.BalanceKey("Greeter", nodes, key, nil)
+		if owner != node.Address {
+			assert.Equal(t, nodes[4].Address, node.Address)
+			moved++
+		}
+	}
+	assert.True(t, moved > 100 && moved < 350)
+
+	assert.Nil(t, b.BalanceKey("Greeter", nil, "user-1", nil))
+	assert.NotNil(t, b.Balance("Greeter", nodes))
+
+	// the nodes rejected by the filter are skipped clockwise, the ring isn't rebuilt
+	for key, owner := range owners {
+		node := b.BalanceKey("Greeter", nodes, key, func(node *Node) bool { return node.Address != owner })
+		assert.NotEqual(t, owner, node.Address)
+	}
+	assert.Nil(t, b.BalanceKey("Greeter", nodes, "user-1", func(*Node) bool { return false }))
+}
+
+func TestSortPoints(t *testing.T) {
+	a, b := &Node{Address: "10.0.0.1:8000"}, &Node{Address: "10.0.0.2:8000"}
+	points := []point{{hash: 2, node: a}, {hash: 1, node: b}, {hash: 1, node: a}}
+	sortPoints(points)
+	assert.Equal(t, []point{{hash: 1, node: a}, {hash: 1, node: b}, {hash: 2, node: a}}, points)
+}
+
+func TestPickHashKey(t *testing.T) {
+	nodes := hashNodes(3)
+	balancer := GetBalancer(ConsistentHash)
+
+	first, err := Pick("hash", nodes, balancer, WithHashKey("user-123"))
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		node, err := Pick("hash", nodes, balancer, WithHashKey("user-123"))
+		assert.Nil(t, err)
+		assert.Equal(t, first, node)
+	}
+
+	// a retry goes to another node
+	node, err := Pick("hash", nodes, balancer, WithHashKey("user-123"), WithExclude(first.Address))
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, node)
+
+	// and the key comes back once the node can be picked again
+	node, err = Pick("hash", nodes, balancer, WithHashKey("user-123"))
+	assert.Nil(t, err)
+	assert.Equal(t, first, node)
+}
@@ -0,0 +1,204 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lubanproj/gorpc/log"
+)
+
+// DefaultDNSRefreshInterval is how long the records of a domain are cached before they are refreshed
+const DefaultDNSRefreshInterval = 30 * time.Second
+
+// DNSOptions defines the options of a DNS selector
+type DNSOptions struct {
+	Resolver        *net.Resolver // resolver of the records, net.DefaultResolver if nil
+	RefreshInterval time.Duration // how long the records are cached, DefaultDNSRefreshInterval if 0
+	Balancer        string        // balancer of the nodes, RoundRobin if empty
+	Timeout         time.Duration // timeout of a lookup, 5s if 0
+}
+
+type DNSOption func(*DNSOptions)
+
+// WithResolver returns a DNSOption which sets the resolver of the records, e.g. : a resolver dialing a stub DNS server
+func WithResolver(resolver *net.Resolver) DNSOption {
+	return func(o *DNSOptions) {
+		o.Resolver = resolver
+	}
+}
+
+// WithRefreshInterval returns a DNSOption which sets how long the records are cached
+func WithRefreshInterval(interval time.Duration) DNSOption {
+	return func(o *DNSOptions) {
+		o.RefreshInterval = interval
+	}
+}
+
+// WithDNSBalancer returns a DNSOption which sets the balancer of the nodes
+func WithDNSBalancer(balancer string) DNSOption {
+	return func(o *DNSOptions) {
+		o.Balancer = balancer
+	}
+}
+
+// dnsSelector resolves the nodes of a domain, the records are cached and refreshed in the background
+// once they are older than the refresh interval. The last good records are kept when DNS fails.
+// net.Resolver doesn't expose the TTLs of the records, the refresh interval stands for them.
+type dnsSelector struct {
+	opts *DNSOptions
+	host string
+	port string // empty for SRV records
+
+	mu         sync.Mutex
+	nodes      []*Node
+	resolved   time.Time // when the nodes were resolved
+	refreshing bool
+}
+
+// NewDNSSelector creates a selector of a domain, which resolves the A/AAAA records of host:port,
+// or the SRV records of a name without port, e.g. : _gorpc._tcp.svc.local. The weight and
+// priority of the SRV records are carried by the nodes.
+func NewDNSSelector(endpoint string, opts ...DNSOption) (Selector, error) {
+	o := &DNSOptions{
+		Resolver:        net.DefaultResolver,
+		RefreshInterval: DefaultDNSRefreshInterval,
+		Balancer:        RoundRobin,
+		Timeout:         5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	d := &dnsSelector{
+		opts: o,
+		host: endpoint,
+	}
+	if strings.Contains(endpoint, ":") {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		d.host, d.port = host, port
+	}
+	if d.host == "" {
+		return nil, errors.New("dns target has no host")
+	}
+
+	return d, nil
+}
+
+// newDNSSelector builds the selector of dns://domain:port or dns://_service._proto.domain,
+// the refresh interval can be set by a query, e.g. : dns://svc.local:8000?refresh=10s
+func newDNSSelector(target Target) (Selector, error) {
+	opts := []DNSOption{WithDNSBalancer(target.Balancer())}
+	if refresh := target.Query.Get("refresh"); refresh != "" {
+		interval, err := time.ParseDuration(refresh)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithRefreshInterval(interval))
+	}
+	return NewDNSSelector(target.Endpoint, opts...)
+}
+
+func (d *dnsSelector) Select(serviceName string, opts ...Option) (string, error) {
+	nodes, err := d.resolve()
+	if err != nil {
+		return "", err
+	}
+
+	node, err := Pick(serviceName, nodes, GetBalancer(d.opts.Balancer), opts...)
+	if err != nil {
+		return "", err
+	}
+	return node.Address, nil
+}
+
+// resolve returns the cached nodes, the first call resolves them synchronously,
+// the later ones trigger a refresh in the background once they are stale
+func (d *dnsSelector) resolve() ([]*Node, error) {
+	d.mu.Lock()
+	nodes := d.nodes
+	stale := time.Since(d.resolved) >= d.opts.RefreshInterval
+	if nodes != nil && stale && !d.refreshing {
+		d.refreshing = true
+		go d.refresh()
+	}
+	d.mu.Unlock()
+
+	if nodes != nil {
+		return nodes, nil
+	}
+
+	nodes, err := d.lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.nodes, d.resolved = nodes, time.Now()
+	d.mu.Unlock()
+
+	return nodes, nil
+}
+
+// refresh updates the cached nodes, they are kept if the lookup fails
+func (d *dnsSelector) refresh() {
+	nodes, err := d.lookup()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.refreshing = false
+	if err != nil {
+		// retried at the next refresh interval with the last good nodes
+		log.Errorf("dns refresh of %s error, keep the last good nodes, %v", d.host, err)
+		d.resolved = time.Now()
+		return
+	}
+	d.nodes, d.resolved = nodes, time.Now()
+}
+
+func (d *dnsSelector) lookup() ([]*Node, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+
+	if d.port == "" {
+		return d.lookupSRV(ctx)
+	}
+
+	addrs, err := d.opts.Resolver.LookupHost(ctx, d.host)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, 0, len(addrs))
+	for _, addr := range addrs {
+		nodes = append(nodes, &Node{Address: net.JoinHostPort(addr, d.port)})
+	}
+	return nodes, nil
+}
+
+func (d *dnsSelector) lookupSRV(ctx context.Context) ([]*Node, error) {
+	_, srvs, err := d.opts.Resolver.LookupSRV(ctx, "", "", d.host)
+	if err != nil {
+		return nil, err
+	}
+	if len(srvs) == 0 {
+		return nil, errors.New("no srv records of " + d.host)
+	}
+
+	nodes := make([]*Node, 0, len(srvs))
+	for _, srv := range srvs {
+		nodes = append(nodes, &Node{
+			Address:  net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))),
+			Weight:   int(srv.Weight),
+			Priority: int(srv.Priority),
+		})
+	}
+	return nodes, nil
+}
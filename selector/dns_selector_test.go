@@ -0,0 +1,188 @@
+package selector
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDNS is a DNS server answering the A and SRV records it's given
+type stubDNS struct {
+	conn net.PacketConn
+
+	mu   sync.Mutex
+	a    map[string][]net.IP
+	srv  map[string][]dnsmessage.SRVResource
+	fail bool
+}
+
+func newStubDNS(t *testing.T) *stubDNS {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := &stubDNS{
+		conn: conn,
+		a:    make(map[string][]net.IP),
+		srv:  make(map[string][]dnsmessage.SRVResource),
+	}
+	go s.serve()
+	return s
+}
+
+// resolver returns a resolver querying the stub
+func (s *stubDNS) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "udp", s.conn.LocalAddr().String())
+		},
+	}
+}
+
+func (s *stubDNS) set(a map[string][]net.IP, srv map[string][]dnsmessage.SRVResource, fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.a, s.srv, s.fail = a, srv, fail
+}
+
+func (s *stubDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var req dnsmessage.Message
+		if err := req.Unpack(buf[:n]); err != nil || len(req.Questions) == 0 {
+			continue
+		}
+		if rsp, err := s.answer(req); err == nil {
+			s.conn.WriteTo(rsp, addr)
+		}
+	}
+}
+
+func (s *stubDNS) answer(req dnsmessage.Message) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := req.Questions[0]
+	rsp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true},
+		Questions: req.Questions,
+	}
+	name := q.Name.String()
+	ttl := uint32(60)
+
+	switch {
+	case s.fail:
+		rsp.RCode = dnsmessage.RCodeServerFailure
+	case q.Type == dnsmessage.TypeA && s.a[name] != nil:
+		for _, ip := range s.a[name] {
+			var a [4]byte
+			copy(a[:], ip.To4())
+			rsp.Answers = append(rsp.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+				Body:   &dnsmessage.AResource{A: a},
+			})
+		}
+	case q.Type == dnsmessage.TypeSRV && s.srv[name] != nil:
+		for i := range s.srv[name] {
+			rsp.Answers = append(rsp.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: ttl},
+				Body:   &s.srv[name][i],
+			})
+		}
+	case s.a[name] == nil && s.srv[name] == nil:
+		rsp.RCode = dnsmessage.RCodeNameError
+	}
+
+	return rsp.Pack()
+}
+
+func TestDNSSelector(t *testing.T) {
+	stub := newStubDNS(t)
+	defer stub.conn.Close()
+
+	stub.set(map[string][]net.IP{
+		"svc.gorpc.test.": {net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+	}, nil, false)
+
+	sel, err := NewDNSSelector("svc.gorpc.test:8000", WithResolver(stub.resolver()), WithRefreshInterval(20*time.Millisecond))
+	assert.Nil(t, err)
+
+	count := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		addr, err := sel.Select("dns")
+		assert.Nil(t, err)
+		count[addr]++
+	}
+	assert.Equal(t, 2, count["10.0.0.1:8000"])
+	assert.Equal(t, 2, count["10.0.0.2:8000"])
+
+	// the records are refreshed in the background
+	stub.set(map[string][]net.IP{
+		"svc.gorpc.test.": {net.ParseIP("10.0.0.3")},
+	}, nil, false)
+	time.Sleep(30 * time.Millisecond)
+	sel.Select("dns")
+	assert.Eventually(t, func() bool {
+		addr, err := sel.Select("dns")
+		return err == nil && addr == "10.0.0.3:8000"
+	}, time.Second, 10*time.Millisecond)
+
+	// the last good records are kept when DNS fails
+	stub.set(nil, nil, true)
+	time.Sleep(30 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		addr, err := sel.Select("dns")
+		assert.Nil(t, err)
+		assert.Equal(t, "10.0.0.3:8000", addr)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the first resolution fails
+	sel, err = NewDNSSelector("unknown.gorpc.test:8000", WithResolver(stub.resolver()))
+	assert.Nil(t, err)
+	_, err = sel.Select("dns")
+	assert.NotNil(t, err)
+}
+
+func TestDNSSelectorSRV(t *testing.T) {
+	stub := newStubDNS(t)
+	defer stub.conn.Close()
+
+	target := func(name string) dnsmessage.Name {
+		return dnsmessage.MustNewName(name)
+	}
+	stub.set(nil, map[string][]dnsmessage.SRVResource{
+		"_gorpc._tcp.svc.gorpc.test.": {
+			{Priority: 1, Weight: 3, Port: 8000, Target: target("a.gorpc.test.")},
+			{Priority: 1, Weight: 1, Port: 8001, Target: target("b.gorpc.test.")},
+			{Priority: 2, Weight: 1, Port: 8002, Target: target("c.gorpc.test.")},
+		},
+	}, false)
+
+	s, err := NewDNSSelector("_gorpc._tcp.svc.gorpc.test", WithResolver(stub.resolver()), WithDNSBalancer(WeightedRoundRobin))
+	assert.Nil(t, err)
+	nodes, err := s.(*dnsSelector).resolve()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(nodes))
+
+	// the weights are used and the backup node of lower priority is not picked
+	count := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		addr, err := s.Select("srv")
+		assert.Nil(t, err)
+		count[addr]++
+	}
+	assert.Equal(t, 30, count["a.gorpc.test:8000"])
+	assert.Equal(t, 10, count["b.gorpc.test:8001"])
+	assert.Equal(t, 0, count["c.gorpc.test:8002"])
+}
@@ -2,7 +2,12 @@ package selector
 
 // 服务节点的基本信息
 type Node struct {
-	Key    string
-	Value  []byte
-	weight int //权重
+	Key      string
+	Value    []byte
+	Address  string // address of the node, e.g. : 127.0.0.1:8000
+	Weight   int    // 权重, weighted balancers take DefaultWeight if <= 0
+	Priority int    // the nodes of the lowest priority are picked as long as one of them is available, e.g. : SRV priority
 }
+
+// DefaultWeight is the weight of the nodes whose weight is not set
+const DefaultWeight = 1
@@ -0,0 +1,201 @@
+package selector
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+)
+
+const (
+	// ewmaDecay is the time for the weight of a latency sample to decay to 1/e
+	ewmaDecay = 10 * time.Second
+
+	// failurePenalty is the latency a failed call counts for unless it was slower
+	failurePenalty = time.Second
+)
+
+// Feedback is implemented by the balancers which learn from the results of the calls. Pick calls Picked
+// for the node picked if the caller asked for the completion of the call by WithDone, the function returned,
+// which may be nil, is called once the call completes. Report fans out the results of all the calls to them.
+type Feedback interface {
+	Picked(serviceName, addr string) func()
+	Report(serviceName, addr string, latency time.Duration, err error)
+}
+
+// p2cBalancer picks the less loaded of two random nodes, the load of a node is its
+// latency EWMA weighted by the requests in flight, which are fed back by Report
+type p2cBalancer struct {
+	mu    sync.RWMutex
+	loads map[string]map[string]*nodeLoad // service name -> address -> load
+	rand  *lockedRand
+}
+
+func newP2CBalancer() *p2cBalancer {
+	return &p2cBalancer{
+		loads: make(map[string]map[string]*nodeLoad),
+		rand:  newLockedRand(),
+	}
+}
+
+// nodeLoad is the load of a node
+type nodeLoad struct {
+	inflight int64 // requests in flight
+
+	mu         sync.Mutex
+	ewma       float64 // latency EWMA in nanoseconds, the average of the other nodes until the first sample
+	lastUpdate time.Time
+}
+
+// cost returns the load of the node
+func (l *nodeLoad) cost() float64 {
+	l.mu.Lock()
+	ewma := l.ewma
+	l.mu.Unlock()
+
+	return (ewma + 1) * float64(atomic.LoadInt64(&l.inflight)+1)
+}
+
+func (l *nodeLoad) observe(latency time.Duration, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// a peak EWMA : slower calls are taken at once, so that a degrading node is avoided quickly
+	if l.lastUpdate.IsZero() || float64(latency) > l.ewma {
+		l.ewma = float64(latency)
+	} else {
+		w := math.Exp(-float64(now.Sub(l.lastUpdate)) / float64(ewmaDecay))
+		l.ewma = l.ewma*w + float64(latency)*(1-w)
+	}
+	l.lastUpdate = now
+}
+
+// sampled returns the latency EWMA of the node and whether it has latency samples
+func (l *nodeLoad) sampled() (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ewma, !l.lastUpdate.IsZero()
+}
+
+func (p *p2cBalancer) load(serviceName, addr string) *nodeLoad {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.loads[serviceName][addr]
+}
+
+// sync returns the loads of the service once they match the nodes : the loads of the nodes gone are dropped,
+// and the new nodes start with the average latency of the others, so that they are not flooded until they
+// report their first calls
+func (p *p2cBalancer) sync(serviceName string, nodes []*Node) map[string]*nodeLoad {
+	p.mu.RLock()
+	loads := p.loads[serviceName]
+	same := len(loads) == len(nodes)
+	for i := 0; same && i < len(nodes); i++ {
+		_, same = loads[nodes[i].Address]
+	}
+	p.mu.RUnlock()
+	if same {
+		return loads
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := p.loads[serviceName]
+	loads = make(map[string]*nodeLoad, len(nodes))
+	var sum float64
+	var n int
+	for _, node := range nodes {
+		if l, ok := old[node.Address]; ok {
+			loads[node.Address] = l
+			if ewma, ok := l.sampled(); ok {
+				sum += ewma
+				n++
+			}
+		}
+	}
+	for _, node := range nodes {
+		if _, ok := loads[node.Address]; ok {
+			continue
+		}
+		l := &nodeLoad{}
+		if n > 0 {
+			l.ewma = sum / float64(n)
+		}
+		loads[node.Address] = l
+	}
+	p.loads[serviceName] = loads
+	return loads
+}
+
+func (p *p2cBalancer) Balance(serviceName string, nodes []*Node) *Node {
+	return p.BalanceFilter(serviceName, nodes, nil)
+}
+
+// BalanceFilter keeps the loads of the nodes, and picks among the ones accepted by the filter
+func (p *p2cBalancer) BalanceFilter(serviceName string, nodes []*Node, filter Filter) *Node {
+	loads := p.sync(serviceName, nodes)
+
+	candidates := nodes
+	if filter != nil {
+		candidates = make([]*Node, 0, len(nodes))
+		for _, node := range nodes {
+			if filter(node) {
+				candidates = append(candidates, node)
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+
+	i := p.rand.Intn(len(candidates))
+	j := p.rand.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := candidates[i], candidates[j]
+	if loads[b.Address].cost() < loads[a.Address].cost() {
+		return b
+	}
+	return a
+}
+
+// Picked counts a request in flight to the node until the function returned is called
+func (p *p2cBalancer) Picked(serviceName, addr string) func() {
+	l := p.load(serviceName, addr)
+	if l == nil {
+		return nil
+	}
+
+	atomic.AddInt64(&l.inflight, 1)
+	return func() {
+		atomic.AddInt64(&l.inflight, -1)
+	}
+}
+
+// Report updates the load of the node with the result of a call, the nodes never balanced are ignored
+func (p *p2cBalancer) Report(serviceName, addr string, latency time.Duration, err error) {
+	l := p.load(serviceName, addr)
+	if l == nil {
+		return
+	}
+
+	// the latency of a call canceled by the caller tells nothing about the node
+	if codes.Code(err) == codes.Canceled {
+		return
+	}
+	if IsNodeFailure(err) && latency < failurePenalty {
+		latency = failurePenalty
+	}
+	l.observe(latency, time.Now())
+}
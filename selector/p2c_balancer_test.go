@@ -0,0 +1,109 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP2CBalancer(t *testing.T) {
+	b := newP2CBalancer()
+	nodes := hashNodes(2)
+
+	// the faster node is picked
+	b.Balance("Greeter", nodes)
+	b.Report("Greeter", nodes[0].Address, 100*time.Millisecond, nil)
+	b.Report("Greeter", nodes[1].Address, time.Millisecond, nil)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, nodes[1], b.Balance("Greeter", nodes))
+	}
+
+	// failures count as slow calls
+	b.Report("Greeter", nodes[1].Address, time.Millisecond, codes.UnavailableError)
+	assert.Equal(t, nodes[0], b.Balance("Greeter", nodes))
+
+	// the requests in flight count until they complete
+	b = newP2CBalancer()
+	b.Balance("Greeter", nodes)
+	done1 := b.Picked("Greeter", nodes[0].Address)
+	done2 := b.Picked("Greeter", nodes[0].Address)
+	assert.Equal(t, nodes[1], b.Balance("Greeter", nodes))
+	done1()
+	done2()
+	done3 := b.Picked("Greeter", nodes[1].Address)
+	assert.Equal(t, nodes[0], b.Balance("Greeter", nodes))
+	done3()
+
+	// the filter applies
+	assert.Equal(t, nodes[0], b.BalanceFilter("Greeter", nodes, func(node *Node) bool { return node == nodes[0] }))
+
+	assert.Nil(t, b.Balance("Greeter", nil))
+	assert.Equal(t, nodes[0], b.Balance("Greeter", nodes[:1]))
+}
+
+func TestP2CNodesChange(t *testing.T) {
+	b := newP2CBalancer()
+	nodes := hashNodes(3)
+
+	b.Balance("Greeter", nodes[:2])
+	b.Report("Greeter", nodes[0].Address, 10*time.Millisecond, nil)
+	b.Report("Greeter", nodes[1].Address, 30*time.Millisecond, nil)
+
+	// the results of the nodes never balanced are ignored
+	b.Report("Greeter", nodes[2].Address, time.Millisecond, nil)
+	assert.Nil(t, b.load("Greeter", nodes[2].Address))
+	assert.Nil(t, b.Picked("Greeter", nodes[2].Address))
+
+	// a new node starts with the average latency of the others
+	b.Balance("Greeter", nodes)
+	assert.Equal(t, float64(20*time.Millisecond), b.load("Greeter", nodes[2].Address).ewma)
+
+	// its first call replaces it
+	b.Report("Greeter", nodes[2].Address, 50*time.Millisecond, nil)
+	assert.Equal(t, float64(50*time.Millisecond), b.load("Greeter", nodes[2].Address).ewma)
+
+	// the loads of the nodes gone are dropped
+	b.Balance("Greeter", nodes[1:])
+	assert.Nil(t, b.load("Greeter", nodes[0].Address))
+	assert.Equal(t, float64(30*time.Millisecond), b.load("Greeter", nodes[1].Address).ewma)
+	assert.Len(t, b.loads["Greeter"], 2)
+}
+
+func TestReportFeedback(t *testing.T) {
+	nodes := hashNodes(3)
+
+	// the calls in flight are counted only if the caller reports their completion
+	node, err := Pick("feedback", nodes, GetBalancer(P2C))
+	assert.Nil(t, err)
+	load := P2CBalancer.load("feedback", node.Address)
+	assert.Equal(t, int64(0), load.inflight)
+
+	var done func()
+	node, err = Pick("feedback", nodes, GetBalancer(P2C), WithDone(&done))
+	assert.Nil(t, err)
+	load = P2CBalancer.load("feedback", node.Address)
+	assert.Equal(t, int64(1), load.inflight)
+
+	// the calls picked by the other balancers don't change the calls in flight
+	Report("feedback", node.Address, 10*time.Millisecond, nil)
+	assert.Equal(t, int64(1), load.inflight)
+	assert.Equal(t, float64(10*time.Millisecond), load.ewma)
+
+	done()
+	assert.Equal(t, int64(0), load.inflight)
+}
+
+func BenchmarkP2CBalancer(b *testing.B) {
+	nodes := hashNodes(10)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			node := P2CBalancer.Balance("bench", nodes)
+			done := P2CBalancer.Picked("bench", node.Address)
+			P2CBalancer.Report("bench", node.Address, time.Millisecond, nil)
+			done()
+		}
+	})
+}
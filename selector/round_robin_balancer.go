@@ -12,18 +12,22 @@ type roundRobinBalancer struct {
 }
 
 type roundRobinPicker struct {
+	mu             sync.Mutex    // 多个协程共享同一个 picker
 	length         int           // 服务列表的长度
 	lastUpdateTime time.Time     // 上次访问时间
 	duration       time.Duration // 更新间隔(多长时间更新一次) 继承自 roundRobinBalancer.duration
 	lastIndex      int           // 上次访问下标
 }
 
-//从一个服务列表里面去获取一个服务节点
-func (rp *roundRobinPicker) pick(nodes []*Node) *Node {
+//从一个服务列表里面去获取一个服务节点, the nodes rejected by the filter are skipped, nil if they all are
+func (rp *roundRobinPicker) pick(nodes []*Node, filter Filter) *Node {
 	if len(nodes) == 0 {
 		return nil
 	}
 
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
 	// 超过时间后或者节点数量有变化时更新
 	if time.Now().Sub(rp.lastUpdateTime) > rp.duration || len(nodes) != rp.length {
 		rp.length = len(nodes)
@@ -31,35 +35,36 @@ func (rp *roundRobinPicker) pick(nodes []*Node) *Node {
 		rp.lastIndex = 0
 	}
 
-	// 两层含义：1、如果只有一个节点就返回第一个节点 2、如果到最后一个节点，下标归零
-	if rp.lastIndex == len(nodes)-1 {
-		rp.lastIndex = 0
-		return nodes[0]
+	for i := 0; i < len(nodes); i++ {
+		// 两层含义：1、如果只有一个节点就返回第一个节点 2、如果到最后一个节点，下标归零
+		if rp.lastIndex == len(nodes)-1 {
+			rp.lastIndex = 0
+		} else {
+			//节点加1
+			rp.lastIndex += 1
+		}
+		if node := nodes[rp.lastIndex]; filter == nil || filter(node) {
+			return node
+		}
 	}
-
-	//节点加1
-	rp.lastIndex += 1
-	return nodes[rp.lastIndex]
+	return nil
 }
 
 func (r *roundRobinBalancer) Balance(serviceName string, nodes []*Node) *Node {
+	return r.BalanceFilter(serviceName, nodes, nil)
+}
 
-	var picker *roundRobinPicker
+// BalanceFilter goes round all the nodes of the service, skipping those rejected by the filter
+func (r *roundRobinBalancer) BalanceFilter(serviceName string, nodes []*Node, filter Filter) *Node {
 
-	// 加载节点信息
-	if p, ok := r.pickers.Load(serviceName); !ok { //未找到就初始化
-		picker = &roundRobinPicker{
-			lastUpdateTime: time.Now(),
-			duration:       r.duration,
-			length:         len(nodes),
-		}
-	} else {
-		picker = p.(*roundRobinPicker)
-	}
+	// 加载节点信息, 未找到就初始化
+	p, _ := r.pickers.LoadOrStore(serviceName, &roundRobinPicker{
+		lastUpdateTime: time.Now(),
+		duration:       r.duration,
+		length:         len(nodes),
+	})
 
-	node := picker.pick(nodes)           //获取访问的服务节点
-	r.pickers.Store(serviceName, picker) //存储
-	return node
+	return p.(*roundRobinPicker).pick(nodes, filter) //获取访问的服务节点
 }
 
 func newRoundRobinBalancer() *roundRobinBalancer {
@@ -0,0 +1,41 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundRobinBalancer(t *testing.T) {
+	b := newRoundRobinBalancer()
+	nodes := hashNodes(4)
+
+	for i := 1; i <= 8; i++ {
+		assert.Equal(t, nodes[i%4], b.Balance("Greeter", nodes))
+	}
+
+	// the nodes rejected by the filter are skipped
+	skip := func(node *Node) bool { return node != nodes[1] }
+	assert.Equal(t, nodes[2], b.BalanceFilter("Greeter", nodes, skip))
+	assert.Nil(t, b.BalanceFilter("Greeter", nodes, func(*Node) bool { return false }))
+}
+
+func TestPickRoundRobinExclude(t *testing.T) {
+	nodes := hashNodes(4)
+	balancer := newRoundRobinBalancer()
+
+	for i := 1; i <= 3; i++ {
+		node, err := Pick("rr.Exclude", nodes, balancer)
+		assert.Nil(t, err)
+		assert.Equal(t, nodes[i], node)
+	}
+
+	// a retry excluding the next node doesn't reset the rotation
+	node, err := Pick("rr.Exclude", nodes, balancer, WithExclude(nodes[0].Address))
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[1], node)
+
+	node, err = Pick("rr.Exclude", nodes, balancer)
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[2], node)
+}
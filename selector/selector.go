@@ -1,8 +1,15 @@
 package selector
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+)
+
 // Selector obtains a service node through service discovery and load balancing
 type Selector interface {
-	Select(string) (string, error)
+	Select(string, ...Option) (string, error)
 }
 
 type defaultSelector struct {
@@ -11,11 +18,36 @@ type defaultSelector struct {
 
 // Options defines Selector options
 type Options struct {
-
+	Exclude []string // addresses of the nodes not to be picked, e.g. : the nodes already tried by a retry
+	HashKey string   // key of the request used by a KeyBalancer, e.g. : a user id for request affinity
+	Done    *func()  // set by Pick to the function to call once the call to the node picked completes
 }
 
 type Option func(*Options)
 
+// WithExclude returns an Option which excludes the nodes of the addresses
+func WithExclude(addrs ...string) Option {
+	return func(o *Options) {
+		o.Exclude = append(o.Exclude, addrs...)
+	}
+}
+
+// WithHashKey returns an Option which routes the request by the key if the balancer is a KeyBalancer
+func WithHashKey(key string) Option {
+	return func(o *Options) {
+		o.HashKey = key
+	}
+}
+
+// WithDone returns an Option which asks Pick to set done to the function to call once the call to the
+// node picked completes, so that the Feedback balancers count the calls in flight. done is set to nil
+// if there is nothing to call.
+func WithDone(done *func()) Option {
+	return func(o *Options) {
+		o.Done = done
+	}
+}
+
 func init() {
 	RegisterSelector("default", DefaultSelector)
 }
@@ -33,7 +65,7 @@ func RegisterSelector(name string, selector Selector) {
 	selectorMap[name] = selector
 }
 
-func (d *defaultSelector) Select(serviceName string) (string, error) {
+func (d *defaultSelector) Select(serviceName string, opts ...Option) (string, error) {
 
 	return "", nil
 }
@@ -46,3 +78,127 @@ func GetSelector(name string) Selector {
 	return DefaultSelector
 }
 
+// Pick filters the nodes by the circuit breakers, the priorities and the options, and picks one by the balancer.
+// A FilterBalancer is given all the nodes with a Filter of the candidates instead, so that its state is kept
+// by the full node set. A node warming up turns down a part of its picks, which are picked again without it.
+// Selectors which resolve the nodes of a service use it, so that the options work the same way for all of them.
+func Pick(serviceName string, nodes []*Node, balancer Balancer, opts ...Option) (*Node, error) {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	candidates := available(serviceName, nodes)
+	if len(nodes) > 0 && len(candidates) == 0 {
+		return nil, codes.NewFrameworkError(codes.Unavailable, fmt.Sprintf("circuit breakers of all the nodes of %s are open", serviceName))
+	}
+	candidates = exclude(preferred(candidates), o.Exclude)
+
+	balance := func(candidates []*Node) *Node {
+		if kb, ok := balancer.(KeyBalancer); ok && o.HashKey != "" {
+			return kb.BalanceKey(serviceName, nodes, o.HashKey, in(candidates))
+		}
+		if fb, ok := balancer.(FilterBalancer); ok {
+			return fb.BalanceFilter(serviceName, nodes, in(candidates))
+		}
+		return balancer.Balance(serviceName, candidates)
+	}
+
+	now := time.Now()
+	warm := warmUp(serviceName, nodes, now)
+	node := balance(candidates)
+	if node != nil && !warm.admit(node.Address, now) {
+		if other := balance(exclude(candidates, []string{node.Address})); other != nil {
+			node = other
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("no services find in %s", serviceName)
+	}
+	acquireBreaker(serviceName, node.Address)
+	if f, ok := balancer.(Feedback); ok && o.Done != nil {
+		*o.Done = f.Picked(serviceName, node.Address)
+	}
+
+	return node, nil
+}
+
+// Report feeds the result of a call to a node to the circuit breaker of the node
+// and to the balancers implementing Feedback, the client reports each attempt
+func Report(serviceName, addr string, latency time.Duration, err error) {
+	if addr == "" {
+		return
+	}
+
+	b := getBreaker(serviceName, addr, true)
+	if from, to, changed := b.report(err, time.Now()); changed {
+		notify(serviceName, addr, from, to)
+	}
+
+	for _, balancer := range balancerMap {
+		if f, ok := balancer.(Feedback); ok {
+			f.Report(serviceName, addr, latency, err)
+		}
+	}
+}
+
+// preferred returns the nodes of the lowest priority
+func preferred(nodes []*Node) []*Node {
+	if len(nodes) == 0 {
+		return nodes
+	}
+
+	min := nodes[0].Priority
+	for _, node := range nodes {
+		if node.Priority < min {
+			min = node.Priority
+		}
+	}
+
+	var left []*Node
+	for _, node := range nodes {
+		if node.Priority == min {
+			left = append(left, node)
+		}
+	}
+	return left
+}
+
+// exclude removes the nodes of the addresses, all the nodes are kept if none would be left,
+// a node already tried is better than no node at all
+func exclude(nodes []*Node, addrs []string) []*Node {
+	if len(addrs) == 0 {
+		return nodes
+	}
+
+	var left []*Node
+	for _, node := range nodes {
+		if !contains(addrs, node.Address) {
+			left = append(left, node)
+		}
+	}
+	if len(left) == 0 {
+		return nodes
+	}
+	return left
+}
+
+// in returns a Filter of the nodes whose addresses are those of the candidates
+func in(candidates []*Node) Filter {
+	addrs := make(map[string]bool, len(candidates))
+	for _, node := range candidates {
+		addrs[node.Address] = true
+	}
+	return func(node *Node) bool {
+		return addrs[node.Address]
+	}
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -26,7 +26,7 @@ func InitMockConsul() *MockConsul {
 	}
 }
 
-func (c *MockConsul) Select(serviceName string) (string, error) {
+func (c *MockConsul) Select(serviceName string, opts ...Option) (string, error) {
 
 	rand.Seed(time.Now().UnixNano())
 	nodeList, ok := c.serivePool[serviceName]
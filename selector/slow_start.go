@@ -0,0 +1,107 @@
+package selector
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slowStartMinFraction is the fraction of its calls a node starts with
+const slowStartMinFraction = 0.1
+
+var (
+	slowStartWindow int64    // time.Duration, 0 if slow start is disabled
+	firstSeen       sync.Map // service name -> *warmUps
+	slowStartRand   = newLockedRand()
+)
+
+// SetSlowStart sets the slow-start window : a node discovered after the first resolution of its service
+// keeps from a tenth to all of the picks the balancer gives it over the window, the picks it turns down
+// go to the other candidates. It works with all the balancers, 0 disables it, which is the default.
+func SetSlowStart(window time.Duration) {
+	atomic.StoreInt64(&slowStartWindow, int64(window))
+	firstSeen.Range(func(serviceName, _ interface{}) bool {
+		firstSeen.Delete(serviceName)
+		return true
+	})
+}
+
+// warmUps keeps when the nodes of a service appeared
+type warmUps struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time // address -> when the node appeared, zero for the nodes of the first resolution
+}
+
+// warmUp records the nodes resolved, it returns nil if slow start is disabled
+func warmUp(serviceName string, nodes []*Node, now time.Time) *warmUps {
+	window := time.Duration(atomic.LoadInt64(&slowStartWindow))
+	if window <= 0 {
+		return nil
+	}
+
+	v, loaded := firstSeen.LoadOrStore(serviceName, &warmUps{window: window})
+	w := v.(*warmUps)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !loaded {
+		// the nodes of the first resolution are warm
+		w.seen = make(map[string]time.Time, len(nodes))
+		for _, node := range nodes {
+			w.seen[node.Address] = time.Time{}
+		}
+		return w
+	}
+
+	// a node which disappeared from the resolution warms up again when it comes back
+	if len(w.seen) == len(nodes) {
+		same := true
+		for i := 0; same && i < len(nodes); i++ {
+			_, same = w.seen[nodes[i].Address]
+		}
+		if same {
+			return w
+		}
+	}
+	current := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		current[node.Address] = true
+		if _, ok := w.seen[node.Address]; !ok {
+			w.seen[node.Address] = now
+		}
+	}
+	for addr := range w.seen {
+		if !current[addr] {
+			delete(w.seen, addr)
+		}
+	}
+	return w
+}
+
+// admit returns whether a call the balancer gave to the node is kept, a node warming up keeps its fraction of them
+func (w *warmUps) admit(addr string, now time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	w.mu.Lock()
+	seen := w.seen[addr]
+	w.mu.Unlock()
+
+	fraction := slowStartFraction(now.Sub(seen), w.window)
+	return fraction >= 1 || slowStartRand.Float64() < fraction
+}
+
+// slowStartFraction returns the fraction of its calls a node keeps after warming up for elapsed
+func slowStartFraction(elapsed, window time.Duration) float64 {
+	if elapsed >= window {
+		return 1
+	}
+	fraction := float64(elapsed) / float64(window)
+	if fraction < slowStartMinFraction {
+		fraction = slowStartMinFraction
+	}
+	return fraction
+}
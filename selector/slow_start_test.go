@@ -0,0 +1,61 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowStart(t *testing.T) {
+	defer SetSlowStart(0)
+	SetSlowStart(time.Minute)
+
+	// every balancer gives a node warming up a fraction of its share
+	balancers := map[string]Balancer{
+		"slow-random": newRandomBalancer(),
+		"slow-rr":     newRoundRobinBalancer(),
+		"slow-wrr":    newWeightedRoundRobinBalancer(),
+		"slow-p2c":    newP2CBalancer(),
+	}
+	for serviceName, balancer := range balancers {
+		// the nodes of the first resolution are warm
+		nodes := hashNodes(2)
+		_, err := Pick(serviceName, nodes, balancer)
+		assert.Nil(t, err)
+
+		nodes = hashNodes(3)
+		count := make(map[string]int)
+		for i := 0; i < 3000; i++ {
+			node, err := Pick(serviceName, nodes, balancer)
+			assert.Nil(t, err)
+			count[node.Address]++
+		}
+		assert.True(t, count[nodes[2].Address] > 0, serviceName)
+		assert.True(t, count[nodes[2].Address] < count[nodes[0].Address]/5, serviceName)
+
+		// the weights seen by the balancer are not changed
+		assert.Equal(t, 0, nodes[2].Weight)
+	}
+
+	// the only candidate is picked even if it's warming up
+	nodes := hashNodes(3)
+	for i := 0; i < 10; i++ {
+		node, err := Pick("slow-rr", nodes, balancers["slow-rr"], WithExclude(nodes[0].Address, nodes[1].Address))
+		assert.Nil(t, err)
+		assert.Equal(t, nodes[2].Address, node.Address)
+	}
+}
+
+func TestSlowStartFraction(t *testing.T) {
+	window := 10 * time.Second
+	assert.Equal(t, 0.1, slowStartFraction(0, window))
+	assert.Equal(t, 0.15, slowStartFraction(1500*time.Millisecond, window))
+	assert.Equal(t, 0.5, slowStartFraction(5*time.Second, window))
+	assert.Equal(t, 1.0, slowStartFraction(10*time.Second, window))
+
+	// slow start is disabled by default
+	nodes := hashNodes(2)
+	assert.Nil(t, warmUp("slow", nodes, time.Now()))
+	assert.True(t, warmUp("slow", nodes, time.Now()).admit(nodes[0].Address, time.Now()))
+}
@@ -0,0 +1,156 @@
+package selector
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Target is a target URI of the client, e.g. : ip://10.0.0.1:8000,10.0.0.2:8000?balancer=p2c
+type Target struct {
+	Scheme   string     // scheme which picks the resolver, e.g. : ip、dns、consul、unix
+	Endpoint string     // what the resolver resolves, e.g. : a list of addresses, a domain, a service name
+	Query    url.Values // parameters of the resolver, e.g. : balancer=p2c
+}
+
+// Balancer returns the name of the balancer of the target, RoundRobin by default
+func (t Target) Balancer() string {
+	if name := t.Query.Get("balancer"); name != "" {
+		return name
+	}
+	return RoundRobin
+}
+
+// ParseTarget parses a target URI, ok is false if the target has no scheme, e.g. : 127.0.0.1:8000
+func ParseTarget(target string) (t Target, ok bool, err error) {
+	i := strings.Index(target, "://")
+	if i <= 0 {
+		return Target{}, false, nil
+	}
+
+	t.Scheme = strings.ToLower(target[:i])
+	t.Endpoint = target[i+len("://"):]
+	if j := strings.Index(t.Endpoint, "?"); j >= 0 {
+		if t.Query, err = url.ParseQuery(t.Endpoint[j+1:]); err != nil {
+			return Target{}, true, err
+		}
+		t.Endpoint = t.Endpoint[:j]
+	}
+	if t.Endpoint == "" {
+		return Target{}, true, fmt.Errorf("target %s has no endpoint", target)
+	}
+
+	return t, true, nil
+}
+
+// SelectorBuilder builds the Selector of a target
+type SelectorBuilder func(target Target) (Selector, error)
+
+// NetworkSelector is a Selector whose nodes are reached over a specific network, e.g. : unix
+type NetworkSelector interface {
+	Selector
+	Network() string
+}
+
+var (
+	schemeMu    sync.RWMutex
+	schemeMap   = make(map[string]SelectorBuilder)
+	targetCache = make(map[string]Selector)
+)
+
+func init() {
+	RegisterScheme("ip", newStaticSelector)
+	RegisterScheme("unix", newUnixSelector)
+	RegisterScheme("dns", newDNSSelector)
+}
+
+// RegisterScheme registers the builder of the selectors of a target scheme, so that a target of
+// the scheme given to client.WithTarget picks its selector, e.g. : RegisterScheme("etcd", newEtcdSelector)
+func RegisterScheme(scheme string, builder SelectorBuilder) {
+	schemeMu.Lock()
+	defer schemeMu.Unlock()
+
+	schemeMap[strings.ToLower(scheme)] = builder
+}
+
+// GetTargetSelector returns the Selector of a target URI, selectors are built once per target.
+// ok is false if the target has no scheme, in which case the target is a raw address.
+func GetTargetSelector(target string) (sel Selector, ok bool, err error) {
+	t, ok, err := ParseTarget(target)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	schemeMu.RLock()
+	sel, cached := targetCache[target]
+	builder, registered := schemeMap[t.Scheme]
+	schemeMu.RUnlock()
+
+	if cached {
+		return sel, true, nil
+	}
+	if !registered {
+		return nil, true, fmt.Errorf("scheme %s of target %s is not registered", t.Scheme, target)
+	}
+
+	if sel, err = builder(t); err != nil {
+		return nil, true, err
+	}
+
+	schemeMu.Lock()
+	defer schemeMu.Unlock()
+
+	if cachedSel, ok := targetCache[target]; ok {
+		return cachedSel, true, nil
+	}
+	targetCache[target] = sel
+	return sel, true, nil
+}
+
+// staticSelector balances over a static list of nodes
+type staticSelector struct {
+	nodes    []*Node
+	balancer string
+	network  string
+}
+
+// newStaticSelector builds the selector of ip://host:port,host:port
+func newStaticSelector(target Target) (Selector, error) {
+	s := &staticSelector{
+		balancer: target.Balancer(),
+	}
+	for _, addr := range strings.Split(target.Endpoint, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid address %s, %v", addr, err)
+		}
+		s.nodes = append(s.nodes, &Node{Address: addr})
+	}
+	return s, nil
+}
+
+// newUnixSelector builds the selector of unix:///path/to/socket
+func newUnixSelector(target Target) (Selector, error) {
+	return &staticSelector{
+		nodes:    []*Node{{Address: target.Endpoint}},
+		balancer: target.Balancer(),
+		network:  "unix",
+	}, nil
+}
+
+func (s *staticSelector) Select(serviceName string, opts ...Option) (string, error) {
+	node, err := Pick(serviceName, s.nodes, GetBalancer(s.balancer), opts...)
+	if err != nil {
+		return "", err
+	}
+	return node.Address, nil
+}
+
+// Network returns the network of the nodes, empty means the network of the client
+func (s *staticSelector) Network() string {
+	return s.network
+}
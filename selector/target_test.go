@@ -0,0 +1,81 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTarget(t *testing.T) {
+	_, ok, err := ParseTarget("127.0.0.1:8000")
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	target, ok, err := ParseTarget("ip://10.0.0.1:8000,10.0.0.2:8000?balancer=p2c")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, "ip", target.Scheme)
+	assert.Equal(t, "10.0.0.1:8000,10.0.0.2:8000", target.Endpoint)
+	assert.Equal(t, P2C, target.Balancer())
+
+	target, _, err = ParseTarget("unix:///tmp/gorpc.sock")
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/gorpc.sock", target.Endpoint)
+	assert.Equal(t, RoundRobin, target.Balancer())
+
+	_, ok, err = ParseTarget("consul://")
+	assert.True(t, ok)
+	assert.NotNil(t, err)
+}
+
+func TestGetTargetSelector(t *testing.T) {
+	target := "ip://10.0.0.1:8000,10.0.0.2:8000"
+	sel, ok, err := GetTargetSelector(target)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// the nodes are balanced
+	count := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		addr, err := sel.Select("target")
+		assert.Nil(t, err)
+		count[addr]++
+	}
+	assert.Equal(t, 5, count["10.0.0.1:8000"])
+	assert.Equal(t, 5, count["10.0.0.2:8000"])
+
+	// selectors are built once per target
+	cached, _, _ := GetTargetSelector(target)
+	assert.Equal(t, sel, cached)
+
+	_, _, err = GetTargetSelector("ip://10.0.0.1")
+	assert.NotNil(t, err)
+	_, _, err = GetTargetSelector("etcd://svc")
+	assert.NotNil(t, err)
+
+	sel, _, err = GetTargetSelector("unix:///tmp/gorpc.sock")
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", sel.(NetworkSelector).Network())
+	addr, err := sel.Select("target")
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/gorpc.sock", addr)
+
+	sel, _, err = GetTargetSelector("dns://localhost:8000")
+	assert.Nil(t, err)
+	addr, err = sel.Select("target")
+	assert.Nil(t, err)
+	assert.Contains(t, []string{"127.0.0.1:8000", "[::1]:8000"}, addr)
+}
+
+func TestRegisterScheme(t *testing.T) {
+	RegisterScheme("mock", func(target Target) (Selector, error) {
+		return InitMockConsul(), nil
+	})
+
+	sel, ok, err := GetTargetSelector("mock://Greeter")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	addr, err := sel.Select("Greeter")
+	assert.Nil(t, err)
+	assert.Contains(t, nodeList, addr)
+}
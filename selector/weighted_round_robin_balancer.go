@@ -22,11 +22,12 @@ func newWeightedRoundRobinBalancer() *weightedRoundRobinBalancer {
 type weightedNode struct {
 	node            *Node
 	weight          int //节点权重
-	effectiveWeight int //节点的有效权重 => 默认是节点权重
+	effectiveWeight int //节点的有效权重 => 默认是节点权重, lowered on failures and recovered on successes
 	currentWeight   int //节点的当前权重 => 默认是节点权重
 }
 
 type wRoundRobinPicker struct {
+	mu             sync.Mutex
 	nodes          []*weightedNode // 服务节点
 	lastUpdateTime time.Time       // 最后更新时间
 	duration       time.Duration   // 更新间隔
@@ -37,9 +38,12 @@ func (wr *wRoundRobinPicker) pick(nodes []*Node) *Node {
 		return nil
 	}
 
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
 	// 当超过更新间隔或者节点发生变化的时候
-	if time.Now().Sub(wr.lastUpdateTime) > wr.duration || len(nodes) != len(wr.nodes) {
-		wr.nodes = getWeightedNode(nodes)
+	if time.Now().Sub(wr.lastUpdateTime) > wr.duration || !sameNodes(wr.nodes, nodes) {
+		wr.nodes = getWeightedNode(nodes, wr.nodes)
 		wr.lastUpdateTime = time.Now()
 	}
 
@@ -47,50 +51,111 @@ func (wr *wRoundRobinPicker) pick(nodes []*Node) *Node {
 	maxWeight := 0   //最大权重
 	index := 0       //选中的节点下标
 	for i, node := range wr.nodes {
-		node.currentWeight += node.weight //每个节点，用它们当前的值加上自己的权重
-		totalWeight += node.weight
+		node.currentWeight += node.effectiveWeight //每个节点，用它们当前的值加上自己的有效权重
+		totalWeight += node.effectiveWeight
 		if node.currentWeight > maxWeight {
 			maxWeight = node.currentWeight
 			index = i
 		}
 	}
 
-	//当前值最大的节点，把它的当前值减去所有节点的权重总和，作为它的新权重
+	//当前值最大的节点，把它的当前值减去所有节点的有效权重总和，作为它的新权重
 	wr.nodes[index].currentWeight -= totalWeight
 
 	return wr.nodes[index].node
 
 }
 
+// report lowers the effective weight of the node on failures and recovers it on successes,
+// so that a failing node gets less requests without being removed
+func (wr *wRoundRobinPicker) report(addr string, failure bool) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	for _, node := range wr.nodes {
+		if node.node.Address != addr {
+			continue
+		}
+		if !failure {
+			if node.effectiveWeight < node.weight {
+				node.effectiveWeight++
+			}
+			return
+		}
+		// the effective weight is lowered by a quarter of the weight, a node keeps a minimal weight to be probed
+		step := node.weight / 4
+		if step < 1 {
+			step = 1
+		}
+		if node.effectiveWeight -= step; node.effectiveWeight < 1 {
+			node.effectiveWeight = 1
+		}
+		return
+	}
+}
+
 func (w *weightedRoundRobinBalancer) Balance(serviceName string, nodes []*Node) *Node {
-	var picker *wRoundRobinPicker
+	p, _ := w.pickers.LoadOrStore(serviceName, &wRoundRobinPicker{
+		lastUpdateTime: time.Now(),
+		duration:       w.duration,
+		nodes:          getWeightedNode(nodes, nil),
+	})
+
+	return p.(*wRoundRobinPicker).pick(nodes)
+}
 
-	if p, ok := w.pickers.Load(serviceName); !ok {
-		picker = &wRoundRobinPicker{
-			lastUpdateTime: time.Now(),
-			duration:       w.duration,
-			nodes:          getWeightedNode(nodes),
+// Picked does nothing, the weights only depend on the results of the calls
+func (w *weightedRoundRobinBalancer) Picked(serviceName, addr string) func() {
+	return nil
+}
+
+// Report adjusts the effective weight of the node by the result of a call
+func (w *weightedRoundRobinBalancer) Report(serviceName, addr string, latency time.Duration, err error) {
+	if p, ok := w.pickers.Load(serviceName); ok {
+		p.(*wRoundRobinPicker).report(addr, IsNodeFailure(err))
+	}
+}
+
+// sameNodes returns whether the weighted nodes are the nodes
+func sameNodes(wgs []*weightedNode, nodes []*Node) bool {
+	if len(wgs) != len(nodes) {
+		return false
+	}
+	for i, node := range nodes {
+		if wgs[i].node.Address != node.Address || wgs[i].weight != nodeWeight(node) {
+			return false
 		}
-		w.pickers.Store(serviceName, picker)
-	} else {
-		picker = p.(*wRoundRobinPicker)
 	}
+	return true
+}
 
-	node := picker.pick(nodes)
-	w.pickers.Store(serviceName, picker)
-	return node
+func nodeWeight(node *Node) int {
+	if node.Weight <= 0 {
+		return DefaultWeight
+	}
+	return node.Weight
 }
 
-// 获取加权节点
-func getWeightedNode(nodes []*Node) []*weightedNode {
+// 获取加权节点, the nodes already known keep the weight they lost on failures
+func getWeightedNode(nodes []*Node, known []*weightedNode) []*weightedNode {
+
+	lost := make(map[string]int, len(known))
+	for _, wg := range known {
+		lost[wg.node.Address] = wg.weight - wg.effectiveWeight
+	}
 
 	var wgs []*weightedNode
 	for _, node := range nodes {
+		weight := nodeWeight(node)
+		effectiveWeight := weight - lost[node.Address] // 默认是节点权重
+		if effectiveWeight < 1 {
+			effectiveWeight = 1
+		}
 		wgs = append(wgs, &weightedNode{
 			node:            node,
-			weight:          node.weight,
-			currentWeight:   node.weight, // 默认是节点权重
-			effectiveWeight: node.weight, // 默认是节点权重
+			weight:          weight,
+			currentWeight:   weight, // 默认是节点权重
+			effectiveWeight: effectiveWeight,
 		})
 	}
 
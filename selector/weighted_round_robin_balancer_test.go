@@ -0,0 +1,52 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedRoundRobinBalancer(t *testing.T) {
+	b := newWeightedRoundRobinBalancer()
+	nodes := []*Node{
+		{Address: "10.0.0.1:8000", Weight: 3},
+		{Address: "10.0.0.2:8000"},
+	}
+
+	count := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		count[b.Balance("Greeter", nodes).Address]++
+	}
+	assert.Equal(t, 30, count[nodes[0].Address])
+	assert.Equal(t, 10, count[nodes[1].Address])
+
+	// failures lower the effective weight down to 1
+	for i := 0; i < 3; i++ {
+		b.Report("Greeter", nodes[0].Address, time.Millisecond, codes.UnavailableError)
+	}
+	count = make(map[string]int)
+	for i := 0; i < 40; i++ {
+		count[b.Balance("Greeter", nodes).Address]++
+	}
+	assert.InDelta(t, 20, count[nodes[0].Address], 1)
+
+	// successes recover it
+	for i := 0; i < 3; i++ {
+		b.Report("Greeter", nodes[0].Address, time.Millisecond, nil)
+	}
+	count = make(map[string]int)
+	for i := 0; i < 40; i++ {
+		count[b.Balance("Greeter", nodes).Address]++
+	}
+	assert.InDelta(t, 30, count[nodes[0].Address], 1)
+
+	// the effective weights are kept when the nodes change
+	b.Report("Greeter", nodes[0].Address, time.Millisecond, codes.UnavailableError)
+	nodes = append(nodes, &Node{Address: "10.0.0.3:8000"})
+	picker, _ := b.pickers.Load("Greeter")
+	b.Balance("Greeter", nodes)
+	assert.Equal(t, 2, picker.(*wRoundRobinPicker).nodes[0].effectiveWeight)
+}
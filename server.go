@@ -12,6 +12,7 @@ import (
 	"github.com/lubanproj/gorpc/log"
 	"github.com/lubanproj/gorpc/plugin"
 	"github.com/lubanproj/gorpc/plugin/jaeger"
+	"github.com/lubanproj/gorpc/registry"
 )
 
 //  gorpc Server 一个服务器可以有一个或多个服务
@@ -228,9 +229,35 @@ func (s *Server) ServeHttp() {
 func (s *Server) Close() {
 	s.closing = false
 
+	// the server is removed from the registries before it stops serving
+	for _, p := range s.plugins {
+		r, ok := p.(registry.Registry)
+		if !ok {
+			continue
+		}
+		for _, instance := range s.instances() {
+			if err := r.Deregister(instance); err != nil {
+				log.Errorf("registry deregister error, %v", err)
+			}
+		}
+	}
+
 	s.service.Close()
 }
 
+// instances returns the instances of the services of the server, which are registered in the registries
+func (s *Server) instances() []*registry.Instance {
+	return []*registry.Instance{
+		{
+			ServiceName: s.service.Name(),
+			Address:     s.opts.address,
+			Weight:      s.opts.weight,
+			Tags:        s.opts.tags,
+			Meta:        s.opts.meta,
+		},
+	}
+}
+
 // 初始化插件
 func (s *Server) InitPlugins() error {
 	// init plugins
@@ -248,11 +275,23 @@ func (s *Server) InitPlugins() error {
 				plugin.WithSelectorSvrAddr(s.opts.selectorSvrAddr),
 				plugin.WithSvrAddr(s.opts.address),
 				plugin.WithServices(services),
+				plugin.WithWeight(s.opts.weight),
+				plugin.WithTags(s.opts.tags),
+				plugin.WithMeta(s.opts.meta),
+				plugin.WithHealthCheck(s.opts.healthCheck),
 			}
 			if err := val.Init(pluginOpts...); err != nil {
 				log.Errorf("resolver init error, %v", err)
 				return err
 			}
+		// 如果是无需初始化的注册中心, e.g. : memory、file
+		case registry.Registry:
+			for _, instance := range s.instances() {
+				if err := val.Register(instance); err != nil {
+					log.Errorf("registry register error, %v", err)
+					return err
+				}
+			}
 		//	如果是链路追踪插件
 		case plugin.TracingPlugin:
 
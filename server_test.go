@@ -3,6 +3,7 @@ package gorpc
 import (
 	"testing"
 
+	"github.com/lubanproj/gorpc/registry"
 	"github.com/lubanproj/gorpc/testdata"
 
 	"github.com/stretchr/testify/assert"
@@ -13,3 +14,20 @@ func TestRegisterService(t *testing.T) {
 	err := s.RegisterService("helloworld", new(testdata.Service))
 	assert.Nil(t, err)
 }
+
+func TestServerRegistry(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1:8000"), WithPlugin(registry.MemoryName), WithWeight(3), WithTags("canary"))
+	assert.Nil(t, s.RegisterService("helloworld.Greeter", new(testdata.Service)))
+	assert.Nil(t, s.InitPlugins())
+
+	instances, err := registry.DefaultMemoryRegistry.Resolve("helloworld.Greeter")
+	assert.Nil(t, err)
+	assert.Equal(t, []*registry.Instance{
+		{ServiceName: "helloworld.Greeter", Address: "127.0.0.1:8000", Weight: 3, Tags: []string{"canary"}},
+	}, instances)
+
+	// the server is deregistered when it closes
+	s.Close()
+	_, err = registry.DefaultMemoryRegistry.Resolve("helloworld.Greeter")
+	assert.Equal(t, registry.ErrNoInstances, err)
+}
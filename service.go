@@ -2,8 +2,10 @@ package gorpc
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/lubanproj/gorpc/codec"
 	"github.com/lubanproj/gorpc/codes"
@@ -11,6 +13,7 @@ import (
 	"github.com/lubanproj/gorpc/log"
 	"github.com/lubanproj/gorpc/metadata"
 	"github.com/lubanproj/gorpc/protocol"
+	"github.com/lubanproj/gorpc/stream"
 	"github.com/lubanproj/gorpc/transport"
 	"github.com/lubanproj/gorpc/utils"
 
@@ -72,6 +75,14 @@ func (s *service) Serve(opts *ServerOptions) {
 		transport.WithServerTimeout(s.opts.timeout),
 		transport.WithSerializationType(s.opts.serializationType),
 		transport.WithProtocol(s.opts.protocol),
+		transport.WithAllowCIDRs(s.opts.allowCIDRs...),
+		transport.WithDenyCIDRs(s.opts.denyCIDRs...),
+		transport.WithMaxConns(s.opts.maxConns),
+		transport.WithMaxConnsPerIP(s.opts.maxConnsPerIP),
+		transport.WithIdleTimeout(s.opts.idleTimeout),
+		transport.WithReadTimeout(s.opts.readTimeout),
+		transport.WithWriteTimeout(s.opts.writeTimeout),
+		transport.WithServerTransportAuth(s.opts.transportAuth),
 	}
 
 	serverTransport := transport.GetServerTransport(s.opts.protocol)
@@ -109,6 +120,9 @@ func (s *service) Handle(ctx context.Context, reqbuf []byte) ([]byte, error) {
 	}
 
 	ctx = metadata.WithServerMetadata(ctx, request.Metadata)
+	if len(s.opts.forwardMetadata) > 0 {
+		ctx = metadata.NewForwardContext(ctx, s.opts.forwardMetadata)
+	}
 
 	serverSerialization := codec.GetSerialization(s.opts.serializationType)
 
@@ -120,9 +134,11 @@ func (s *service) Handle(ctx context.Context, reqbuf []byte) ([]byte, error) {
 		return nil
 	}
 
-	if s.opts.timeout != 0 {
+	// the handler runs within min(client deadline, server timeout), outbound calls
+	// made by the handler with this ctx inherit the remaining budget
+	if timeout := handlerTimeout(request.Metadata, s.opts.timeout); timeout != 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, s.opts.timeout)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
 
@@ -133,10 +149,18 @@ func (s *service) Handle(ctx context.Context, reqbuf []byte) ([]byte, error) {
 
 	handler := s.handlers[method]
 	if handler == nil {
-		return nil, errors.New("handlers is nil")
+		return nil, codes.NewFrameworkError(codes.Unimplemented, fmt.Sprintf("method %s not implemented", request.ServicePath))
 	}
 
-	rsp, err := handler(ctx, s.svr, dec, s.opts.interceptors)
+	// expose the raw request to server interceptors, e.g. request signature verification
+	ctx, serverStream := stream.NewServerStream(ctx)
+	serverStream.WithServicePath(request.ServicePath)
+	serverStream.WithMethod(method)
+	serverStream.WithPayload(request.Payload)
+
+	rsp, err := s.invoke(ctx, request.ServicePath, func(ctx context.Context) (interface{}, error) {
+		return handler(ctx, s.svr, dec, s.opts.interceptors)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -148,3 +172,65 @@ func (s *service) Handle(ctx context.Context, reqbuf []byte) ([]byte, error) {
 
 	return rspbuf, nil
 }
+
+// handlerResult is the result of a handler call
+type handlerResult struct {
+	rsp interface{}
+	err error
+}
+
+// handlerOverruns counts the handlers which are still running when their deadline expires
+var handlerOverruns int64
+
+// GetHandlerOverruns returns the number of handlers that overran their deadline in the process
+func GetHandlerOverruns() int64 {
+	return atomic.LoadInt64(&handlerOverruns)
+}
+
+// invoke calls the handler and answers as soon as ctx is done, so that a handler which
+// ignores ctx can't hold up the connection. The late result of the handler is dropped.
+func (s *service) invoke(ctx context.Context, servicePath string,
+	call func(context.Context) (interface{}, error)) (interface{}, error) {
+
+	done := make(chan handlerResult, 1)
+	go func() {
+		rsp, err := call(ctx)
+		done <- handlerResult{rsp: rsp, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.rsp, result.err
+	case <-ctx.Done():
+	}
+
+	if ctx.Err() != context.DeadlineExceeded {
+		// canceled by the client, nobody waits for the response
+		return nil, codes.CanceledError
+	}
+
+	atomic.AddInt64(&handlerOverruns, 1)
+	log.Errorf("handler %s overran its deadline, answer with a timeout", servicePath)
+
+	start := time.Now()
+	go func() {
+		<-done
+		log.Infof("handler %s returned %v after its deadline, the result is dropped", servicePath, time.Since(start))
+	}()
+
+	return nil, codes.DeadlineExceededError
+}
+
+// handlerTimeout returns the smaller of the client timeout and the server timeout, 0 means no timeout
+func handlerTimeout(md map[string][]byte, serverTimeout time.Duration) time.Duration {
+	ms, err := strconv.ParseInt(string(md[metadata.TimeoutKey]), 10, 64)
+	if err != nil || ms <= 0 {
+		return serverTimeout
+	}
+
+	clientTimeout := time.Duration(ms) * time.Millisecond
+	if serverTimeout == 0 || clientTimeout < serverTimeout {
+		return clientTimeout
+	}
+	return serverTimeout
+}
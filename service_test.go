@@ -1,8 +1,18 @@
 package gorpc
 
 import (
+	"context"
 	"testing"
 	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/interceptor"
+	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/protocol"
+	"github.com/lubanproj/gorpc/testdata"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestServe(t *testing.T) {
@@ -18,3 +28,117 @@ func TestServe(t *testing.T) {
 	}()
 	s.Close()
 }
+
+func TestHandleDeadline(t *testing.T) {
+	var remaining time.Duration
+	s := &service{
+		opts: &ServerOptions{
+			timeout:           time.Second,
+			serializationType: "msgpack",
+		},
+		handlers: map[string]Handler{
+			"SayHello": func(ctx context.Context, svr interface{}, dec func(interface{}) error, ceps []interceptor.ServerInterceptor) (interface{}, error) {
+				deadline, _ := ctx.Deadline()
+				remaining = time.Until(deadline)
+				return &testdata.HelloReply{}, nil
+			},
+		},
+	}
+
+	handle := func(md map[string][]byte) {
+		reqbuf, err := proto.Marshal(&protocol.Request{
+			ServicePath: "/helloworld.Greeter/SayHello",
+			Metadata:    md,
+		})
+		assert.Nil(t, err)
+		_, err = s.Handle(context.Background(), reqbuf)
+		assert.Nil(t, err)
+	}
+
+	// the client deadline is shorter
+	handle(map[string][]byte{metadata.TimeoutKey: []byte("100")})
+	assert.True(t, remaining <= 100*time.Millisecond)
+
+	// the server timeout is shorter
+	handle(map[string][]byte{metadata.TimeoutKey: []byte("5000")})
+	assert.True(t, remaining > 100*time.Millisecond && remaining <= time.Second)
+
+	// no client deadline
+	handle(nil)
+	assert.True(t, remaining > 100*time.Millisecond && remaining <= time.Second)
+}
+
+func TestHandleOverrun(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	s := &service{
+		opts: &ServerOptions{
+			timeout:           50 * time.Millisecond,
+			serializationType: "msgpack",
+		},
+		handlers: map[string]Handler{
+			// a handler which ignores ctx
+			"SayHello": func(ctx context.Context, svr interface{}, dec func(interface{}) error, ceps []interceptor.ServerInterceptor) (interface{}, error) {
+				<-release
+				return &testdata.HelloReply{}, nil
+			},
+		},
+	}
+
+	reqbuf, err := proto.Marshal(&protocol.Request{
+		ServicePath: "/helloworld.Greeter/SayHello",
+	})
+	assert.Nil(t, err)
+
+	before := GetHandlerOverruns()
+	start := time.Now()
+	_, err = s.Handle(context.Background(), reqbuf)
+	assert.Equal(t, codes.DeadlineExceededError, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, before+1, GetHandlerOverruns())
+}
+
+func TestHandleForwardMetadata(t *testing.T) {
+	var forwarded metadata.MD
+	s := &service{
+		opts: &ServerOptions{
+			serializationType: "msgpack",
+			forwardMetadata:   []string{"request-id"},
+		},
+		handlers: map[string]Handler{
+			"SayHello": func(ctx context.Context, svr interface{}, dec func(interface{}) error, ceps []interceptor.ServerInterceptor) (interface{}, error) {
+				forwarded = metadata.FromOutgoingContext(metadata.WithClientMetadata(ctx, metadata.OutgoingMetadata(ctx)))
+				return &testdata.HelloReply{}, nil
+			},
+		},
+	}
+
+	reqbuf, err := proto.Marshal(&protocol.Request{
+		ServicePath: "/helloworld.Greeter/SayHello",
+		Metadata: map[string][]byte{
+			"Request-Id": []byte("42"),
+			"tenant":     []byte("t1"),
+		},
+	})
+	assert.Nil(t, err)
+	_, err = s.Handle(context.Background(), reqbuf)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"42"}, forwarded.Get("request-id"))
+	assert.Nil(t, forwarded.Get("tenant"))
+}
+
+func TestHandleUnimplemented(t *testing.T) {
+	s := &service{
+		opts:     &ServerOptions{},
+		handlers: map[string]Handler{},
+	}
+
+	reqbuf, err := proto.Marshal(&protocol.Request{
+		ServicePath: "/helloworld.Greeter/SayGoodbye",
+	})
+	assert.Nil(t, err)
+	_, err = s.Handle(context.Background(), reqbuf)
+	assert.Equal(t, uint32(codes.Unimplemented), codes.Code(err))
+}
@@ -4,7 +4,9 @@ import "context"
 
 type ServerStream struct {
 	ctx context.Context
+	ServicePath string // 请求服务路径 e.g. : /helloworld.Greeter/SayHello
 	Method string // 方法名
+	Payload []byte // 请求体，用于签名校验等需要原始数据的场景
 	RetCode uint32 // 返回码 0—成功 非0-失败
 	RetMsg  string  // 返回信息 OK-成功，失败返回具体信息
 }
@@ -14,8 +16,9 @@ const ServerStreamKey = StreamContextKey("GORPC_SERVER_STREAM")
 func GetServerStream(ctx context.Context) *ServerStream {
 	v := ctx.Value(ServerStreamKey)
 	if v == nil {
-		cs := &ServerStream{}
-		context.WithValue(ctx, ServerStreamKey, cs)
+		return &ServerStream{
+			ctx : ctx,
+		}
 	}
 	return v.(*ServerStream)
 }
@@ -25,6 +28,16 @@ func (ss *ServerStream) WithMethod(method string) *ServerStream {
 	return ss
 }
 
+func (ss *ServerStream) WithServicePath(servicePath string) *ServerStream {
+	ss.ServicePath = servicePath
+	return ss
+}
+
+func (ss *ServerStream) WithPayload(payload []byte) *ServerStream {
+	ss.Payload = payload
+	return ss
+}
+
 func (ss *ServerStream) Clone() *ServerStream {
 	return &ServerStream{
 		Method : ss.Method,
@@ -3,18 +3,21 @@ package transport
 import (
 	"time"
 
+	"github.com/lubanproj/gorpc/auth"
 	"github.com/lubanproj/gorpc/pool/connpool"
 	"github.com/lubanproj/gorpc/selector"
 )
 
 // ClientTransportOptions includes all ClientTransport parameter options
 type ClientTransportOptions struct {
-	Target      string
-	ServiceName string
-	Network     string
-	Pool        connpool.Pool
-	Selector    selector.Selector  //服务发现
-	Timeout     time.Duration
+	Target        string
+	ServiceName   string
+	Network       string
+	Pool          connpool.Pool
+	Selector      selector.Selector //服务发现
+	SelectOptions []selector.Option // options of the node selection, e.g. : the nodes to exclude
+	Timeout       time.Duration
+	TransportAuth auth.TransportAuth // handshakes with the server on new connections, e.g. : tls
 }
 
 // Use the Options mode to wrap the ClientTransportOptions
@@ -61,3 +64,17 @@ func WithTimeout(timeout time.Duration) ClientTransportOption {
 		o.Timeout = timeout
 	}
 }
+
+// WithClientTransportAuth returns a ClientTransportOption which sets the value for transportAuth
+func WithClientTransportAuth(transportAuth auth.TransportAuth) ClientTransportOption {
+	return func(o *ClientTransportOptions) {
+		o.TransportAuth = transportAuth
+	}
+}
+
+// WithSelectOptions returns a ClientTransportOption which sets the value for selectOptions
+func WithSelectOptions(opts ...selector.Option) ClientTransportOption {
+	return func(o *ClientTransportOptions) {
+		o.SelectOptions = opts
+	}
+}
@@ -2,10 +2,19 @@ package transport
 
 import (
 	"context"
+	"net"
+	"sync/atomic"
+	"time"
 
+	"github.com/lubanproj/gorpc/auth"
+	"github.com/lubanproj/gorpc/codec"
 	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/peer"
 )
 
+// cancelWriteTimeout is the max duration for sending a cancel frame
+const cancelWriteTimeout = 100 * time.Millisecond
+
 type clientTransport struct {
 	opts *ClientTransportOptions
 }
@@ -46,11 +55,16 @@ var New = func() ClientTransport {
 
 func (c *clientTransport) Send(ctx context.Context, req []byte, opts ...ClientTransportOption) ([]byte, error) {
 
+	// apply the options to a copy, the transport is shared by concurrent calls
+	callOpts := *c.opts
 	for _, o := range opts {
-		o(c.opts)
+		o(&callOpts)
+	}
+	c = &clientTransport{
+		opts: &callOpts,
 	}
 
-	if c.opts.Network == "tcp" {
+	if c.opts.Network == "tcp" || c.opts.Network == "unix" {
 		return c.SendTcpReq(ctx, req)
 	}
 
@@ -64,7 +78,7 @@ func (c *clientTransport) Send(ctx context.Context, req []byte, opts ...ClientTr
 func (c *clientTransport) SendTcpReq(ctx context.Context, req []byte) ([]byte, error) {
 
 	// service discovery
-	addr, err := c.opts.Selector.Select(c.opts.ServiceName)
+	addr, err := c.opts.Selector.Select(c.opts.ServiceName, c.opts.SelectOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -74,36 +88,142 @@ func (c *clientTransport) SendTcpReq(ctx context.Context, req []byte) ([]byte, e
 		addr = c.opts.Target
 	}
 
-	conn, err := c.opts.Pool.Get(ctx, c.opts.Network, addr)
-	//	conn, err := net.DialTimeout("tcp", addr, c.opts.Timeout);
+	// record the picked node
+	p, _ := peer.FromContext(ctx)
+	if p != nil {
+		p.Addr = peer.NewAddr(c.opts.Network, addr)
+	}
+
+	conn, authInfo, err := c.dial(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
 
+	if p != nil {
+		p.LocalAddr = conn.LocalAddr()
+		p.AuthInfo = authInfo
+	}
+
 	defer conn.Close()
 
+	// a hung server must not block the caller past its deadline
+	if deadline, ok := c.deadline(ctx); ok {
+		conn.SetDeadline(deadline)
+	}
+
 	sendNum := 0
 	num := 0
 	for sendNum < len(req) {
 		num, err = conn.Write(req[sendNum:])
 		if err != nil {
-			return nil, err
+			return nil, ioError(err)
 		}
 		sendNum += num
 
 		if err = isDone(ctx); err != nil {
-			return nil, err
+			// a partially written request leaves the connection unusable
+			markUnusable(conn)
+			return nil, codes.FromContextError(err)
 		}
 	}
 
+	// cancel the server handler if ctx is done before the response arrives, once the response
+	// is read a ctx done meanwhile must not close the connection, which goes back to the pool
+	var state int32 // 0 : reading, 1 : read or canceled
+	stop := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-ctx.Done():
+			if atomic.CompareAndSwapInt32(&state, 0, 1) {
+				cancelRequest(conn)
+			}
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-watched
+	}()
+
 	// parse frame
-	wrapperConn := wrapConn(conn)
-	frame, err := wrapperConn.framer.ReadFrame(conn)
+	frame, err := NewFramer().ReadFrame(conn)
+	if err == nil && atomic.CompareAndSwapInt32(&state, 0, 1) {
+		return frame, nil
+	}
+
+	// the request was canceled, or the read failed
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, codes.FromContextError(ctxErr)
+	}
+	return nil, ioError(err)
+}
+
+// dial gets a connection from the pool, or dials a dedicated connection and handshakes
+// with the server if transport authentication is configured, since the pool can't
+// tell authenticated connections apart
+func (c *clientTransport) dial(ctx context.Context, addr string) (net.Conn, auth.AuthInfo, error) {
+	if c.opts.TransportAuth == nil {
+		conn, err := c.opts.Pool.Get(ctx, c.opts.Network, addr)
+		if err != nil {
+			return nil, nil, dialError(ctx, err)
+		}
+		return conn, nil, nil
+	}
+
+	dialer := &net.Dialer{Timeout: c.opts.Timeout}
+	rawConn, err := dialer.DialContext(ctx, c.opts.Network, addr)
 	if err != nil {
-		return nil, err
+		return nil, nil, dialError(ctx, err)
+	}
+
+	conn, authInfo, err := c.opts.TransportAuth.ClientHandshake(ctx, addr, rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, nil, codes.NewFrameworkError(codes.ClientCertFail, err.Error())
+	}
+
+	return conn, authInfo, nil
+}
+
+// dialError converts a dial failure into Unavailable, or into the context error if ctx is done
+func dialError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return codes.FromContextError(ctx.Err())
+	}
+	return codes.NewFrameworkError(codes.Unavailable, err.Error())
+}
+
+// cancelRequest notifies the server to cancel the request in flight by a cancel frame,
+// then closes the connection, which still has the abandoned response to come
+func cancelRequest(conn net.Conn) {
+	if frame, err := codec.NewControlFrame(codec.MsgTypeCancel); err == nil {
+		conn.SetWriteDeadline(time.Now().Add(cancelWriteTimeout))
+		conn.Write(frame)
 	}
+	markUnusable(conn)
+	conn.Close()
+}
 
-	return frame, err
+// markUnusable prevents a pooled connection from being reused
+func markUnusable(conn net.Conn) {
+	if pc, ok := conn.(interface{ MarkUnusable() }); ok {
+		pc.MarkUnusable()
+	}
+}
+
+
+// deadline derives the socket deadline from the call context,
+// or from the transport timeout if the context has no deadline
+func (c *clientTransport) deadline(ctx context.Context) (time.Time, bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline, true
+	}
+	if c.opts.Timeout > 0 {
+		return time.Now().Add(c.opts.Timeout), true
+	}
+	return time.Time{}, false
 }
 
 func isDone(ctx context.Context) error {
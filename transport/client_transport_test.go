@@ -1,7 +1,14 @@
 package transport
 
 import (
+	"context"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/pool/connpool"
+	"github.com/lubanproj/gorpc/selector"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +27,82 @@ func TestGetClientTransport(t *testing.T) {
 	clientTransport = GetClientTransport("test")
 	assert.Equal(t, clientTransport, DefaultClientTransport)
 }
+
+func TestSendTcpReqTimeout(t *testing.T) {
+	// a hung server which accepts connections but never replies
+	lis, err := net.Listen("tcp", "127.0.0.1:8011")
+	assert.Nil(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ct := &clientTransport{
+		opts: &ClientTransportOptions{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = ct.Send(ctx, []byte("hello"),
+		WithClientTarget("127.0.0.1:8011"),
+		WithClientNetwork("tcp"),
+		WithClientPool(connpool.GetPool("default")),
+		WithSelector(selector.DefaultSelector),
+	)
+	assert.Equal(t, codes.DeadlineExceededError, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestSendTcpReqUnavailable(t *testing.T) {
+	ct := &clientTransport{
+		opts: &ClientTransportOptions{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// nothing listens on the address
+	_, err := ct.Send(ctx, []byte("hello"),
+		WithClientTarget(freeAddr(t)),
+		WithClientNetwork("tcp"),
+		WithClientPool(connpool.GetPool("default")),
+		WithSelector(selector.DefaultSelector),
+	)
+	assert.Equal(t, uint32(codes.Unavailable), codes.Code(err))
+}
+
+func TestSendTcpReqConnectionClosed(t *testing.T) {
+	// a server which closes the connections without replying
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	ct := &clientTransport{
+		opts: &ClientTransportOptions{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = ct.Send(ctx, []byte("hello"),
+		WithClientTarget(lis.Addr().String()),
+		WithClientNetwork("tcp"),
+		WithClientPool(connpool.GetPool("default")),
+		WithSelector(selector.DefaultSelector),
+	)
+	assert.Equal(t, uint32(codes.Unavailable), codes.Code(err))
+}
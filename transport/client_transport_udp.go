@@ -2,14 +2,16 @@ package transport
 
 import (
 	"context"
+	"io"
 	"net"
 
 	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/peer"
 )
 
 func (c *clientTransport) SendUdpReq(ctx context.Context, req []byte) ([]byte, error) {
 	// service discovery
-	addr, err := c.opts.Selector.Select(c.opts.ServiceName)
+	addr, err := c.opts.Selector.Select(c.opts.ServiceName, c.opts.SelectOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -19,6 +21,12 @@ func (c *clientTransport) SendUdpReq(ctx context.Context, req []byte) ([]byte, e
 		addr = c.opts.Target
 	}
 
+	// record the picked node
+	p, _ := peer.FromContext(ctx)
+	if p != nil {
+		p.Addr = peer.NewAddr(c.opts.Network, addr)
+	}
+
 	udpAddr, err := net.ResolveUDPAddr(c.opts.Network, addr)
 	if err != nil {
 		return nil, codes.NewFrameworkError(codes.ClientMsgErrorCode, "addr invalid ...")
@@ -31,14 +39,25 @@ func (c *clientTransport) SendUdpReq(ctx context.Context, req []byte) ([]byte, e
 
 	defer conn.Close()
 
+	if p != nil {
+		p.LocalAddr = conn.LocalAddr()
+	}
+
+	if deadline, ok := c.deadline(ctx); ok {
+		conn.SetDeadline(deadline)
+	}
+
 	if n, err := conn.Write(req); n != len(req) || err != nil {
-		return nil, err
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return nil, ioError(err)
 	}
 
 	recvBuf := make([]byte, 65536)
 	n, err := conn.Read(recvBuf);
 	if err != nil {
-		return nil, err
+		return nil, ioError(err)
 	}
 
 	rsp := recvBuf[:n]
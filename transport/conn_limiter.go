@@ -0,0 +1,167 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ServerStats counts the connections rejected or closed by the server transports,
+// the counters are process-wide and shared by all the services in the process
+type ServerStats struct {
+	DeniedConns     int64 // connections or datagrams rejected by the ip allow/deny lists
+	OverLimitConns  int64 // connections rejected by the connection limits
+	IdleClosedConns int64 // connections closed after being idle for too long
+}
+
+var serverStats ServerStats
+
+// GetServerStats returns a snapshot of the process-wide server transport counters
+func GetServerStats() ServerStats {
+	return ServerStats{
+		DeniedConns:     atomic.LoadInt64(&serverStats.DeniedConns),
+		OverLimitConns:  atomic.LoadInt64(&serverStats.OverLimitConns),
+		IdleClosedConns: atomic.LoadInt64(&serverStats.IdleClosedConns),
+	}
+}
+
+// connLimiter checks the source ip of connections against the allow/deny lists
+// and limits the number of concurrent connections
+type connLimiter struct {
+	allow         []*net.IPNet
+	deny          []*net.IPNet
+	maxConns      int
+	maxConnsPerIP int
+
+	mu         sync.Mutex
+	conns      int
+	connsPerIP map[string]int
+}
+
+func newConnLimiter(opts *ServerTransportOptions) (*connLimiter, error) {
+	allow, err := parseCIDRs(opts.AllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseCIDRs(opts.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connLimiter{
+		allow:         allow,
+		deny:          deny,
+		maxConns:      opts.MaxConns,
+		maxConnsPerIP: opts.MaxConnsPerIP,
+		connsPerIP:    make(map[string]int),
+	}, nil
+}
+
+// parseCIDRs parses CIDRs like 10.0.0.0/8, a single ip is treated as a host network
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %s", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed checks the source ip against the deny list first, then the allow list if it's not empty
+func (l *connLimiter) allowed(ip net.IP) bool {
+	if ip == nil {
+		return len(l.allow) == 0 && len(l.deny) == 0
+	}
+	if containsIP(l.deny, ip) {
+		return false
+	}
+	return len(l.allow) == 0 || containsIP(l.allow, ip)
+}
+
+// acquire takes a connection slot for the ip
+func (l *connLimiter) acquire(ip net.IP) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := ip.String()
+	if l.maxConns > 0 && l.conns >= l.maxConns {
+		return false
+	}
+	if l.maxConnsPerIP > 0 && l.connsPerIP[key] >= l.maxConnsPerIP {
+		return false
+	}
+
+	l.conns++
+	l.connsPerIP[key]++
+	return true
+}
+
+// release gives back the connection slot taken by admit
+func (l *connLimiter) release(addr net.Addr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := ipFromAddr(addr).String()
+	l.conns--
+	if l.connsPerIP[key]--; l.connsPerIP[key] <= 0 {
+		delete(l.connsPerIP, key)
+	}
+}
+
+// admit checks whether a new connection from addr can be served,
+// if it returns true, release must be called when the connection is closed
+func (l *connLimiter) admit(addr net.Addr) bool {
+	ip := ipFromAddr(addr)
+	if !l.allowed(ip) {
+		atomic.AddInt64(&serverStats.DeniedConns, 1)
+		return false
+	}
+	if !l.acquire(ip) {
+		atomic.AddInt64(&serverStats.OverLimitConns, 1)
+		return false
+	}
+	return true
+}
+
+func ipFromAddr(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	if addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnLimiterAllowed(t *testing.T) {
+	limiter, err := newConnLimiter(&ServerTransportOptions{
+		AllowCIDRs: []string{"10.0.0.0/8", "192.168.1.1"},
+		DenyCIDRs:  []string{"10.1.0.0/16"},
+	})
+	assert.Nil(t, err)
+
+	assert.True(t, limiter.allowed(net.ParseIP("10.0.0.1")))
+	assert.True(t, limiter.allowed(net.ParseIP("192.168.1.1")))
+	assert.False(t, limiter.allowed(net.ParseIP("192.168.1.2")))
+	assert.False(t, limiter.allowed(net.ParseIP("10.1.0.1")))
+
+	_, err = newConnLimiter(&ServerTransportOptions{DenyCIDRs: []string{"10.0.0.0/33"}})
+	assert.NotNil(t, err)
+}
+
+func TestConnLimiterAdmit(t *testing.T) {
+	limiter, err := newConnLimiter(&ServerTransportOptions{
+		MaxConns:      3,
+		MaxConnsPerIP: 2,
+	})
+	assert.Nil(t, err)
+
+	addr1 := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1000}
+	addr2 := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 1000}
+
+	before := GetServerStats()
+	assert.True(t, limiter.admit(addr1))
+	assert.True(t, limiter.admit(addr1))
+	assert.False(t, limiter.admit(addr1))
+	assert.True(t, limiter.admit(addr2))
+	assert.False(t, limiter.admit(addr2))
+	assert.Equal(t, before.OverLimitConns+2, GetServerStats().OverLimitConns)
+
+	limiter.release(addr1)
+	assert.True(t, limiter.admit(addr2))
+}
+
+func TestServeConnLimits(t *testing.T) {
+	st := &serverTransport{
+		opts: &ServerTransportOptions{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := freeAddr(t)
+	err := st.ListenAndServe(ctx,
+		WithServerAddress(addr),
+		WithServerNetwork("tcp"),
+		WithMaxConnsPerIP(1),
+		WithIdleTimeout(200*time.Millisecond),
+	)
+	assert.Nil(t, err)
+
+	conn1, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	defer conn1.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// the second connection from the same ip is closed at once
+	conn2, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	defer conn2.Close()
+	conn2.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn2.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	ne, ok := err.(net.Error)
+	assert.False(t, ok && ne.Timeout())
+
+	// the first connection is closed after the idle timeout
+	before := GetServerStats()
+	conn1.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn1.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	ne, ok = err.(net.Error)
+	assert.False(t, ok && ne.Timeout())
+	assert.Equal(t, before.IdleClosedConns+1, GetServerStats().IdleClosedConns)
+}
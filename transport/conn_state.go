@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// serverRequest is a request read from a connection
+type serverRequest struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	seq    uint64 // sequence number returned by connState.start
+	frame  []byte
+}
+
+// connState tracks the request in flight on a server connection and manages the read deadline,
+// the idle timeout only applies when no request is in flight and no frame is being read
+type connState struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	opts    *ServerTransportOptions
+	cancel  context.CancelFunc // cancels the request in flight, nil if no request in flight
+	seq     uint64             // sequence number of the latest request
+	reading bool               // whether a frame is being read
+}
+
+func newConnState(conn net.Conn, opts *ServerTransportOptions) *connState {
+	c := &connState{
+		conn: conn,
+		opts: opts,
+	}
+	c.setWaitDeadline()
+	return c
+}
+
+// setWaitDeadline sets the read deadline for waiting for the next frame, c.mu must be held
+func (c *connState) setWaitDeadline() {
+	if c.reading {
+		return
+	}
+	if c.cancel == nil && c.opts.IdleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.opts.IdleTimeout))
+		return
+	}
+	c.conn.SetReadDeadline(time.Time{})
+}
+
+// beginRead is called when the first byte of a frame arrives
+func (c *connState) beginRead() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reading = true
+	if c.opts.ReadTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout))
+		return
+	}
+	c.conn.SetReadDeadline(time.Time{})
+}
+
+// endRead is called when a frame has been read completely
+func (c *connState) endRead() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reading = false
+	c.setWaitDeadline()
+}
+
+// start registers the cancel func of a request read from the connection,
+// it returns the sequence number to be passed to finish
+func (c *connState) start(cancel context.CancelFunc) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	c.cancel = cancel
+	c.setWaitDeadline()
+	return c.seq
+}
+
+// finish is called when the response of the request has been written
+func (c *connState) finish(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a later request may have been registered already
+	if c.seq != seq {
+		return
+	}
+	c.cancel = nil
+	c.setWaitDeadline()
+}
+
+// cancelRequest cancels the request in flight if any
+func (c *connState) cancelRequest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
@@ -3,6 +3,8 @@ package transport
 import (
 	"context"
 	"time"
+
+	"github.com/lubanproj/gorpc/auth"
 )
 
 // ServerTransportOptions includes all ServerTransport parameter options
@@ -14,6 +16,14 @@ type ServerTransportOptions struct{
 	Handler Handler		   // handler
 	SerializationType string   // serialization type, e.g : proto、json、msgpack
 	KeepAlivePeriod time.Duration // keepalive period
+	AllowCIDRs []string // only the source ips within these CIDRs are served if not empty, e.g. : 10.0.0.0/8
+	DenyCIDRs []string // the source ips within these CIDRs are rejected
+	MaxConns int // max number of concurrent connections, 0 means no limit
+	MaxConnsPerIP int // max number of concurrent connections per source ip, 0 means no limit
+	IdleTimeout time.Duration // a connection without any request during this time will be closed
+	ReadTimeout time.Duration // max duration for reading a request once it arrives
+	WriteTimeout time.Duration // max duration for writing a response
+	TransportAuth auth.TransportAuth // handshakes with the client on accepted connections, e.g. : tls
 }
 
 // Handler defines a common interface for handling packets
@@ -71,4 +81,59 @@ func WithKeepAlivePeriod(keepAlivePeriod time.Duration) ServerTransportOption {
 	return func(o *ServerTransportOptions) {
 		o.KeepAlivePeriod = keepAlivePeriod
 	}
-}
\ No newline at end of file
+}
+
+// WithAllowCIDRs returns a ServerTransportOption which sets the value for allowCIDRs
+func WithAllowCIDRs(cidrs ...string) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.AllowCIDRs = cidrs
+	}
+}
+
+// WithDenyCIDRs returns a ServerTransportOption which sets the value for denyCIDRs
+func WithDenyCIDRs(cidrs ...string) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.DenyCIDRs = cidrs
+	}
+}
+
+// WithMaxConns returns a ServerTransportOption which sets the value for maxConns
+func WithMaxConns(maxConns int) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.MaxConns = maxConns
+	}
+}
+
+// WithMaxConnsPerIP returns a ServerTransportOption which sets the value for maxConnsPerIP
+func WithMaxConnsPerIP(maxConnsPerIP int) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.MaxConnsPerIP = maxConnsPerIP
+	}
+}
+
+// WithIdleTimeout returns a ServerTransportOption which sets the value for idleTimeout
+func WithIdleTimeout(idleTimeout time.Duration) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.IdleTimeout = idleTimeout
+	}
+}
+
+// WithReadTimeout returns a ServerTransportOption which sets the value for readTimeout
+func WithReadTimeout(readTimeout time.Duration) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.ReadTimeout = readTimeout
+	}
+}
+
+// WithWriteTimeout returns a ServerTransportOption which sets the value for writeTimeout
+func WithWriteTimeout(writeTimeout time.Duration) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.WriteTimeout = writeTimeout
+	}
+}
+// WithServerTransportAuth returns a ServerTransportOption which sets the value for transportAuth
+func WithServerTransportAuth(transportAuth auth.TransportAuth) ServerTransportOption {
+	return func(o *ServerTransportOptions) {
+		o.TransportAuth = transportAuth
+	}
+}
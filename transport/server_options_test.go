@@ -54,3 +54,25 @@ func TestWithKeepAlivePeriod(t *testing.T) {
 	fSto(&sto)
 	assert.Equal(t, time.Second*time.Duration(2), sto.KeepAlivePeriod)
 }
+
+func TestWithCIDRs(t *testing.T) {
+	var sto ServerTransportOptions
+	WithAllowCIDRs("10.0.0.0/8")(&sto)
+	WithDenyCIDRs("10.1.0.0/16", "10.2.0.1")(&sto)
+	assert.Equal(t, []string{"10.0.0.0/8"}, sto.AllowCIDRs)
+	assert.Equal(t, []string{"10.1.0.0/16", "10.2.0.1"}, sto.DenyCIDRs)
+}
+
+func TestWithConnLimits(t *testing.T) {
+	var sto ServerTransportOptions
+	WithMaxConns(100)(&sto)
+	WithMaxConnsPerIP(10)(&sto)
+	WithIdleTimeout(time.Minute)(&sto)
+	WithReadTimeout(time.Second)(&sto)
+	WithWriteTimeout(2 * time.Second)(&sto)
+	assert.Equal(t, time.Second, sto.ReadTimeout)
+	assert.Equal(t, 2*time.Second, sto.WriteTimeout)
+	assert.Equal(t, 100, sto.MaxConns)
+	assert.Equal(t, 10, sto.MaxConnsPerIP)
+	assert.Equal(t, time.Minute, sto.IdleTimeout)
+}
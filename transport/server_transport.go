@@ -1,15 +1,21 @@
 package transport
 
 import (
+	"bufio"
 	"context"
 	"io"
 	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/lubanproj/gorpc/auth"
 	"github.com/lubanproj/gorpc/codec"
 	"github.com/lubanproj/gorpc/codes"
 	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/peer"
 	"github.com/lubanproj/gorpc/protocol"
 	"github.com/lubanproj/gorpc/stream"
 	"github.com/lubanproj/gorpc/utils"
@@ -71,13 +77,24 @@ func (s *serverTransport) ListenAndServe(ctx context.Context, opts ...ServerTran
 
 func (s *serverTransport) ListenAndServeTcp(ctx context.Context, opts ...ServerTransportOption) error {
 
+	limiter, err := newConnLimiter(s.opts)
+	if err != nil {
+		return err
+	}
+
 	lis, err := net.Listen(s.opts.Network, s.opts.Address)
 	if err != nil {
 		return err
 	}
 
+	// close the listener when the upstream ctx is done, so that the port is released
 	go func() {
-		if err = s.serve(ctx, lis); err != nil {
+		<-ctx.Done()
+		lis.Close()
+	}()
+
+	go func() {
+		if err = s.serve(ctx, lis, limiter); err != nil && err != ctx.Err() {
 			log.Errorf("transport serve error, %v", err)
 		}
 	}()
@@ -85,7 +102,7 @@ func (s *serverTransport) ListenAndServeTcp(ctx context.Context, opts ...ServerT
 	return nil
 }
 
-func (s *serverTransport) serve(ctx context.Context, lis net.Listener) error {
+func (s *serverTransport) serve(ctx context.Context, lis net.Listener, limiter *connLimiter) error {
 
 	var tempDelay time.Duration
 
@@ -105,6 +122,9 @@ func (s *serverTransport) serve(ctx context.Context, lis net.Listener) error {
 
 		conn, err := tl.AcceptTCP()
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if ne, ok := err.(net.Error); ok && ne.Temporary() {
 				if tempDelay == 0 {
 					tempDelay = 5 * time.Millisecond
@@ -128,12 +148,40 @@ func (s *serverTransport) serve(ctx context.Context, lis net.Listener) error {
 			conn.SetKeepAlivePeriod(s.opts.KeepAlivePeriod)
 		}
 
+		// check the ip allow/deny lists and the connection limits
+		if !limiter.admit(conn.RemoteAddr()) {
+			conn.Close()
+			continue
+		}
+
 		go func() {
 
+			defer limiter.release(conn.RemoteAddr())
+
+			rawConn, authInfo, err := s.handshake(conn)
+			if err != nil {
+				log.Errorf("gorpc tcp conn handshake error, %v", err)
+				conn.Close()
+				return
+			}
+
 			// build stream
 			ctx, _ := stream.NewServerStream(ctx)
 
-			if err := s.handleConn(ctx, wrapConn(conn)); err != nil {
+			ctx = peer.NewContext(ctx, &peer.Peer{
+				Addr:      conn.RemoteAddr(),
+				LocalAddr: conn.LocalAddr(),
+				Network:   s.opts.Network,
+				Protocol:  s.opts.Protocol,
+				AuthInfo:  authInfo,
+			})
+
+			// the identity established by the handshake, e.g. : the tls client certificate
+			if pi, ok := authInfo.(auth.PrincipalInfo); ok && pi.Principal() != "" {
+				ctx = auth.WithPrincipal(ctx, pi.Principal())
+			}
+
+			if err := s.handleConn(ctx, wrapConn(rawConn)); err != nil {
 				log.Errorf("gorpc handle tcp conn error, %v", err)
 			}
 
@@ -144,12 +192,62 @@ func (s *serverTransport) serve(ctx context.Context, lis net.Listener) error {
 	return nil
 }
 
+// handshake performs the transport authentication on an accepted connection if it's configured,
+// the handshake must be completed within the read timeout
+func (s *serverTransport) handshake(conn net.Conn) (net.Conn, auth.AuthInfo, error) {
+	if s.opts.TransportAuth == nil {
+		return conn, nil, nil
+	}
+
+	if s.opts.ReadTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.opts.ReadTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	return s.opts.TransportAuth.ServerHandshake(conn)
+}
+
 func (s *serverTransport) handleConn(ctx context.Context, conn *connWrapper) error {
 
 	// close the connection before return
 	// the connection closes only if a network read or write fails
 	defer conn.Close()
 
+	state := newConnState(conn, s.opts)
+	reqs := make(chan *serverRequest)
+	done := make(chan struct{})
+	defer close(done)
+
+	// frames are read in a separate goroutine, so that a cancel frame or
+	// a client disconnect can be noticed while a request is being handled
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.readLoop(ctx, conn, state, reqs, done)
+		close(reqs)
+	}()
+
+	// requests on a connection are handled serially
+	for req := range reqs {
+		rsp, err := s.handle(req.ctx, req.frame)
+		if err != nil {
+			log.Errorf("s.handle err is not nil, %v", err)
+		}
+
+		err = s.write(ctx, conn, rsp)
+		req.cancel()
+		state.finish(req.seq)
+		if err != nil {
+			return err
+		}
+	}
+
+	return <-errCh
+}
+
+// readLoop reads frames from the connection and passes requests to handleConn
+func (s *serverTransport) readLoop(ctx context.Context, conn *connWrapper, state *connState,
+	reqs chan<- *serverRequest, done <-chan struct{}) error {
+
 	for {
 		// check upstream ctx is done
 		select {
@@ -158,26 +256,53 @@ func (s *serverTransport) handleConn(ctx context.Context, conn *connWrapper) err
 		default:
 		}
 
-		frame, err := s.read(ctx, conn)
-		if err == io.EOF {
-			// read compeleted
-			return nil
+		// wait for the next frame, the connection is closed if
+		// no request arrives during the idle timeout
+		if _, err := conn.reader.Peek(1); err != nil {
+			// the client is gone, cancel the request in flight
+			state.cancelRequest()
+			if isTimeout(err) {
+				atomic.AddInt64(&serverStats.IdleClosedConns, 1)
+				return nil
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
 		}
 
+		// once a frame arrives, it must be read completely within the read timeout
+		state.beginRead()
+
+		frame, err := s.read(ctx, conn)
 		if err != nil {
+			state.cancelRequest()
+			if err == io.EOF {
+				// read compeleted
+				return nil
+			}
 			return err
 		}
 
-		rsp, err := s.handle(ctx, frame)
-		if err != nil {
-			log.Errorf("s.handle err is not nil, %v", err)
+		if codec.GetMsgType(frame) == codec.MsgTypeCancel {
+			state.endRead()
+			state.cancelRequest()
+			continue
 		}
 
-		if err = s.write(ctx, conn, rsp); err != nil {
-			return err
+		// register the cancel func before the handoff, so that a cancel frame
+		// read right after it can't be lost
+		reqCtx, cancel := context.WithCancel(ctx)
+		seq := state.start(cancel)
+		state.endRead()
+
+		select {
+		case reqs <- &serverRequest{ctx: reqCtx, cancel: cancel, seq: seq, frame: frame}:
+		case <-done:
+			cancel()
+			return nil
 		}
 	}
-
 }
 
 func (s *serverTransport) read(ctx context.Context, conn *connWrapper) ([]byte, error) {
@@ -202,12 +327,15 @@ func (s *serverTransport) handle(ctx context.Context, frame []byte) ([]byte, err
 		return nil, err
 	}
 
+	// handlers and interceptors set the response metadata in ctx
+	ctx, rspMetadata := metadata.NewResponseContext(ctx)
+
 	rspbuf, err := s.opts.Handler.Handle(ctx, reqbuf)
 	if err != nil {
 		log.Errorf("server Handle error: %v", err)
 	}
 
-	response := addRspHeader(rspbuf, err)
+	response := addRspHeader(rspbuf, rspMetadata(), err)
 
 	rspPb, err := proto.Marshal(response)
 	if err != nil {
@@ -224,20 +352,35 @@ func (s *serverTransport) handle(ctx context.Context, frame []byte) ([]byte, err
 	return rspbody, nil
 }
 
-func addRspHeader(payload []byte, err error) *protocol.Response {
+func addRspHeader(payload []byte, md map[string][]byte, err error) *protocol.Response {
 	response := &protocol.Response{
 		Payload: payload,
+		Metadata: md,
 		RetCode: codes.OK,
 		RetMsg:  "success",
 	}
 
 	if err != nil {
-		if e, ok := err.(*codes.Error); ok {
-			response.RetCode = e.Code
-			response.RetMsg = e.Message
-		} else {
-			response.RetCode = codes.ServerInternalErrorCode
-			response.RetMsg = codes.ServerInternalError.Message
+		e, ok := codes.FromError(err)
+		if !ok {
+			// don't leak the internal error message to the client
+			e = codes.ServerInternalError
+		}
+		response.RetCode = e.Code
+		response.RetMsg = e.Message
+
+		// the error type and details are carried in reserved metadata keys
+		if response.Metadata == nil {
+			response.Metadata = make(map[string][]byte)
+		}
+		response.Metadata[metadata.ErrorTypeKey] = []byte(strconv.Itoa(e.Type))
+		if len(e.Details) > 0 {
+			details, encodeErr := codes.EncodeDetails(e.Details)
+			if encodeErr != nil {
+				log.Errorf("encode error details error, %v", encodeErr)
+			} else {
+				response.Metadata[metadata.ErrorDetailsKey] = details
+			}
 		}
 	}
 
@@ -245,8 +388,13 @@ func addRspHeader(payload []byte, err error) *protocol.Response {
 }
 
 func (s *serverTransport) write(ctx context.Context, conn net.Conn, rsp []byte) error {
+	if s.opts.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(s.opts.WriteTimeout))
+	}
+
 	if _, err := conn.Write(rsp); err != nil {
 		log.Errorf("conn Write err: %v", err)
+		return err
 	}
 
 	return nil
@@ -255,15 +403,22 @@ func (s *serverTransport) write(ctx context.Context, conn net.Conn, rsp []byte)
 type connWrapper struct {
 	net.Conn
 	framer Framer
+	reader *bufio.Reader // buffered reader, allows waiting for a request without consuming it
 }
 
 func wrapConn(rawConn net.Conn) *connWrapper {
 	return &connWrapper{
 		Conn:   rawConn,
 		framer: NewFramer(),
+		reader: bufio.NewReader(rawConn),
 	}
 }
 
+// Read reads data through the buffered reader
+func (c *connWrapper) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
 func (s *serverTransport) getServerStream(ctx context.Context, request *protocol.Request) (*stream.ServerStream, error) {
 	serverStream := stream.GetServerStream(ctx)
 
@@ -1,7 +1,19 @@
 package transport
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/lubanproj/gorpc/auth"
+	"github.com/lubanproj/gorpc/codec"
+	"github.com/lubanproj/gorpc/codes"
+	"github.com/lubanproj/gorpc/metadata"
+	"github.com/lubanproj/gorpc/peer"
+	"github.com/lubanproj/gorpc/pool/connpool"
+	"github.com/lubanproj/gorpc/selector"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,3 +34,193 @@ func TestGetServerTransport(t *testing.T) {
 }
 
 
+
+type blockingHandler struct {
+	started  chan struct{}
+	canceled chan struct{}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, req []byte) ([]byte, error) {
+	close(h.started)
+	select {
+	case <-ctx.Done():
+		close(h.canceled)
+		return nil, ctx.Err()
+	case <-time.After(5 * time.Second):
+		return req, nil
+	}
+}
+
+// freeAddr returns a local tcp address which is not in use
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func TestClientCancelPropagation(t *testing.T) {
+	handler := &blockingHandler{
+		started:  make(chan struct{}),
+		canceled: make(chan struct{}),
+	}
+	st := &serverTransport{
+		opts: &ServerTransportOptions{},
+	}
+	serverCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	addr := freeAddr(t)
+	err := st.ListenAndServe(serverCtx,
+		WithServerAddress(addr),
+		WithServerNetwork("tcp"),
+		WithHandler(handler),
+	)
+	assert.Nil(t, err)
+
+	req, err := codec.DefaultCodec.Encode([]byte("hello"))
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go func() {
+		<-handler.started
+		cancel()
+	}()
+
+	ct := &clientTransport{
+		opts: &ClientTransportOptions{},
+	}
+	_, err = ct.Send(ctx, req,
+		WithClientTarget(addr),
+		WithClientNetwork("tcp"),
+		WithClientPool(connpool.GetPool("default")),
+		WithSelector(selector.DefaultSelector),
+	)
+	assert.Equal(t, codes.CanceledError, err)
+
+	select {
+	case <-handler.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("server handler is not canceled")
+	}
+}
+
+func TestServeReadTimeout(t *testing.T) {
+	st := &serverTransport{
+		opts: &ServerTransportOptions{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := freeAddr(t)
+	err := st.ListenAndServe(ctx,
+		WithServerAddress(addr),
+		WithServerNetwork("tcp"),
+		WithReadTimeout(100*time.Millisecond),
+	)
+	assert.Nil(t, err)
+
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// a slow client sends only a part of the frame header
+	_, err = conn.Write([]byte{0x11, 0x0})
+	assert.Nil(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	ne, ok := err.(net.Error)
+	assert.False(t, ok && ne.Timeout())
+}
+
+// stubAuth is a TransportAuth which does nothing but report its auth type
+type stubAuth struct{}
+
+func (a *stubAuth) AuthType() string {
+	return "stub"
+}
+
+func (a *stubAuth) Principal() string {
+	return "stub-client"
+}
+
+func (a *stubAuth) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, auth.AuthInfo, error) {
+	return conn, a, nil
+}
+
+func (a *stubAuth) ServerHandshake(conn net.Conn) (net.Conn, auth.AuthInfo, error) {
+	return conn, a, nil
+}
+
+type peerHandler struct {
+	authType chan string
+}
+
+func (h *peerHandler) Handle(ctx context.Context, req []byte) ([]byte, error) {
+	p, _ := peer.FromContext(ctx)
+	if p != nil && p.AuthInfo != nil {
+		h.authType <- p.AuthInfo.AuthType() + "/" + auth.PrincipalFromContext(ctx)
+	} else {
+		h.authType <- ""
+	}
+	return req, nil
+}
+
+func TestTransportAuth(t *testing.T) {
+	handler := &peerHandler{
+		authType: make(chan string, 1),
+	}
+	st := &serverTransport{
+		opts: &ServerTransportOptions{},
+	}
+	serverCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	addr := freeAddr(t)
+	err := st.ListenAndServe(serverCtx,
+		WithServerAddress(addr),
+		WithServerNetwork("tcp"),
+		WithHandler(handler),
+		WithServerTransportAuth(&stubAuth{}),
+	)
+	assert.Nil(t, err)
+
+	req, err := codec.DefaultCodec.Encode([]byte("hello"))
+	assert.Nil(t, err)
+
+	p := &peer.Peer{}
+	ctx, cancel := context.WithTimeout(peer.NewContext(context.Background(), p), 3*time.Second)
+	defer cancel()
+
+	ct := &clientTransport{
+		opts: &ClientTransportOptions{},
+	}
+	_, err = ct.Send(ctx, req,
+		WithClientTarget(addr),
+		WithClientNetwork("tcp"),
+		WithClientPool(connpool.GetPool("default")),
+		WithSelector(selector.DefaultSelector),
+		WithClientTransportAuth(&stubAuth{}),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "stub/stub-client", <-handler.authType)
+	assert.Equal(t, "stub", p.AuthInfo.AuthType())
+}
+
+func TestAddRspHeader(t *testing.T) {
+	rsp := addRspHeader(nil, nil, codes.NewFrameworkError(codes.ConfigErrorCode, "config error").
+		WithDetails(&codes.RetryInfo{RetryDelay: time.Second}))
+	assert.Equal(t, uint32(codes.ConfigErrorCode), rsp.RetCode)
+	assert.Equal(t, "1", string(rsp.Metadata[metadata.ErrorTypeKey]))
+	details, err := codes.DecodeDetails(rsp.Metadata[metadata.ErrorDetailsKey])
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, details[0].(*codes.RetryInfo).RetryDelay)
+
+	// the message of an unknown error is not sent
+	rsp = addRspHeader(nil, nil, errors.New("db password is wrong"))
+	assert.Equal(t, uint32(codes.ServerInternalErrorCode), rsp.RetCode)
+	assert.Equal(t, codes.ServerInternalError.Message, rsp.RetMsg)
+}
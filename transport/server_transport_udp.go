@@ -3,14 +3,21 @@ package transport
 import (
 	"context"
 	"github.com/lubanproj/gorpc/log"
+	"github.com/lubanproj/gorpc/peer"
 	"github.com/lubanproj/gorpc/stream"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
 
 func (s *serverTransport) ListenAndServeUdp(ctx context.Context, opts ...ServerTransportOption) error {
 
+	limiter, err := newConnLimiter(s.opts)
+	if err != nil {
+		return err
+	}
+
 	conn , err := net.ListenPacket(s.opts.Network, s.opts.Address)
 	defer conn.Close()
 
@@ -46,6 +53,12 @@ func (s *serverTransport) ListenAndServeUdp(ctx context.Context, opts ...ServerT
 			return err
 		}
 
+		// datagrams are connectionless, only the ip allow/deny lists apply
+		if !limiter.allowed(ipFromAddr(addr)) {
+			atomic.AddInt64(&serverStats.DeniedConns, 1)
+			continue
+		}
+
 		req := buffer[:num]
 
 		go func() {
@@ -53,6 +66,13 @@ func (s *serverTransport) ListenAndServeUdp(ctx context.Context, opts ...ServerT
 			// build stream
 			ctx, _ := stream.NewServerStream(ctx)
 
+			ctx = peer.NewContext(ctx, &peer.Peer{
+				Addr:      addr,
+				LocalAddr: conn.LocalAddr(),
+				Network:   s.opts.Network,
+				Protocol:  s.opts.Protocol,
+			})
+
 			if err := s.handleUdpConn(ctx, conn, addr, req); err != nil {
 				log.Errorf("gorpc handle udp conn error, %v", err)
 			}
@@ -30,6 +30,25 @@ type ClientTransport interface {
 	Send(context.Context, []byte, ...ClientTransportOption) ([]byte, error)
 }
 
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// ioError converts a read or write failure of a connection into a status : a network timeout into
+// DeadlineExceeded, the other failures, e.g. : io.EOF, a connection reset, into Unavailable.
+// The statuses, e.g. : an invalid frame, are returned as is.
+func ioError(err error) error {
+	if isTimeout(err) {
+		return codes.DeadlineExceededError
+	}
+	if _, ok := err.(*codes.Error); ok {
+		return err
+	}
+	return codes.NewFrameworkError(codes.Unavailable, err.Error())
+}
+
 // Framer 定义从数据流中读取数据帧
 type Framer interface {
 	// 读取数据帧的通用化定义